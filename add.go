@@ -2,13 +2,12 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
@@ -32,34 +31,39 @@ func addNote(args []string) (err error) {
 
 	var optHelp bool
 	var optTemplate string
+	var optMessage string
+	var optFile string
 	fs := flag.NewFlagSet("note add", flag.ContinueOnError)
 	fs.Usage = func() { helpNoteAdd() }
 	fs.BoolVar(&optHelp, "h", false, "Display Help")
 	fs.BoolVar(&optHelp, "help", false, "Display Help")
 	fs.StringVar(&optTemplate, "t", "note", "Template of note")
 	fs.StringVar(&optTemplate, "template", "note", "Template of note")
+	fs.StringVar(&optMessage, "m", "", "Note content")
+	fs.StringVar(&optMessage, "message", "", "Note content")
+	fs.StringVar(&optFile, "f", "", "Read note content from file, - for stdin")
+	fs.StringVar(&optFile, "file", "", "Read note content from file, - for stdin")
 	if err = fs.Parse(args); err != nil {
 		return
 	}
 
 	rargs := fs.Args()
-	for k, arg := range rargs {
+	for _, arg := range fs.Args() {
 		// Arguments prefixed by + are tags
 		if arg[0] == '+' {
-			tag := arg[1:]
-			if regexp.MustCompile(`^[\pL0-9]+$`).MatchString(tag) == false {
-				fmt.Println("Error: Tags must be alphanumeric")
+			tag := normalizeTag(arg[1:])
+			if reTag.MatchString(tag) == false {
+				fmt.Println("Error: Tags must be alphanumeric, levels separated by dots")
 				return
 			}
-			tags = append(tags, arg[1:])
-			rargs = args[k+1:]
+			tags = append(tags, tag)
+			rargs = rargs[1:]
 			continue
 		}
-		//rargs = args[k:]
 		break
 	}
 
-	title := "Undefined"
+	var title string
 	if len(rargs) > 0 {
 		// Everything else is the title
 		title = strings.Join(rargs, " ")
@@ -74,43 +78,104 @@ func addNote(args []string) (err error) {
 	if fs.NArg() == 1 && fs.Arg(0) == "help" {
 		helpNoteAdd()
 	}
-	// Note title missing
+	// Note title missing, and not set by template
 	if fs.NArg() == 0 {
-		helpNoteAdd()
+		tpl, _ := loadTemplate(optTemplate)
+		if tpl.Title == "" {
+			helpNoteAdd()
+		}
+	}
+
+	// Note content supplied by -m, -f or stdin.
+	// No editor is started in this case.
+	content, hasContent, err := addContent(optMessage, optFile)
+	if err != nil {
+		return
+	}
+
+	if hasContent == false {
+		offerNewNoteDraftRecovery()
+	}
+
+	note := Note{
+		Id:          uuid.New(),
+		Title:       title,
+		Tags:        tags,
+		DateCreated: time.Now().UTC(),
+	}
+	err = addNoteFromTemplate(note, optTemplate, content, hasContent)
+	return
+}
+
+// Create note from template. The metadata of the note must be set,
+// except of versions. If content is supplied, the note is created
+// without starting the editor.
+func addNoteFromTemplate(note Note, template string, content []byte, hasContent bool) (err error) {
+	tpl, err := loadTemplate(template)
+	if errors.Is(err, os.ErrNotExist) {
+		tpl, err = NoteTemplate{Name: template}, nil
+	}
+	if err != nil {
+		return
 	}
 
-	in, err := os.ReadFile(filepath.Clean(notemanager.TemplateDir + "/" + optTemplate))
+	// defaults of template front matter
+	err = tpl.Apply(&note)
 	if err != nil {
-		in = []byte{}
+		return
+	}
+	if note.Title == "" {
+		note.Title = "Undefined"
+	}
+
+	id := note.Id
+	timestamp := note.DateCreated
+	file := note.tmpFile()
+
+	in, err := tpl.Render(note, content)
+	if err != nil {
+		return
 	}
-	timestamp := time.Now().UTC()
-	id := uuid.New()
-	file := filepath.Clean(fmt.Sprintf("%s/tmp/%s", notemanager.DataDir, id.String()))
 
-	// Replace placeholders
-	in = bytes.ReplaceAll(in, []byte("{{ nm.id }}"), []byte(id.String()))
-	in = bytes.ReplaceAll(in, []byte("{{ nm.created.date }}"), []byte(timestamp.Format("2006-01-02")))
-	in = bytes.ReplaceAll(in, []byte("{{ nm.created.time }}"), []byte(timestamp.Format("15:04")))
-	in = bytes.ReplaceAll(in, []byte("{{ nm.created.offset }}"), []byte(timestamp.Format("-07:00")))
-	in = bytes.ReplaceAll(in, []byte("{{ nm.title }}"), []byte(title))
-	in = bytes.ReplaceAll(in, []byte("{{ nm.tags }}"), []byte(strings.Join(tags, ",")))
+	// Supplied content is either used by the template,
+	// or appended to it.
+	if hasContent && tpl.UsesContent() == false {
+		in = append(in, content...)
+	}
+
+	if hasContent {
+		err = os.WriteFile(file, in, notemanager.FilePermission)
+		if err != nil {
+			return
+		}
+		note.Versions = []string{timestamp.Format(notemanager.VersionTimeFormat)}
+		err = createNote(note)
+		return
+	}
 
 	// Create a file in temporary dir.
 	// Once the note editor has been closed check if timestamp
 	// is newer than the file. If newer, move the file into
 	// note directory and create data file.
-	err = os.WriteFile(file, in, 0600)
+	err = os.WriteFile(file, in, notemanager.FilePermission)
+	if err != nil {
+		return
+	}
 	fileinfo, err := os.Stat(file)
 	if err != nil {
 		return
 	}
 
-	timestampInitial := fileinfo.ModTime()
-	cmd := exec.Command(notemanager.Editor, file)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
+	// Keep metadata along with the file, so the note can
+	// be recovered with note drafts, if anything goes wrong.
+	draft := Draft{Name: id.String(), NoteId: id}
+	err = os.WriteFile(draft.DataPath(), note.Yaml(), notemanager.FilePermission)
+	if err != nil {
+		return
+	}
 
-	err = cmd.Run()
+	timestampInitial := fileinfo.ModTime()
+	err = runEditor(file, 0)
 	if err != nil {
 		return
 	}
@@ -119,23 +184,94 @@ func addNote(args []string) (err error) {
 		return
 	}
 	timestampAfter := fileinfo.ModTime()
-	versions := []string{
+
+	note.Versions = []string{
 		timestampAfter.UTC().Format(notemanager.VersionTimeFormat),
 	}
+	note.DateCreated = timestampAfter.UTC()
+	if timestampAfter != timestampInitial {
+		err = createNote(note)
+	} else {
+		// nothing written, nothing to recover
+		os.Remove(file)
+	}
+	os.Remove(draft.DataPath())
 
-	note := Note{
-		Id:          id,
-		Title:       title,
-		Versions:    versions,
-		Tags:        tags,
-		DateCreated: timestampAfter.UTC(),
+	return
+}
+
+// Move the temporary file of a new note into its note directory
+// and write the data file.
+func createNote(note Note) (err error) {
+	unlock, err := lockDataDir()
+	if err != nil {
+		return
 	}
-	if timestampAfter != timestampInitial {
-		moveFile(id.String(), timestampAfter.UTC().Format(notemanager.VersionTimeFormat))
-		//metadata.Write()
-		note.WriteData()
-		fmt.Println("Note " + id.String() + " created.")
+	defer unlock()
+
+	err = note.moveTmpFile()
+	if err != nil {
+		return
+	}
+	// alias set by template
+	if note.Alias != "" {
+		if id, exists := aliases.Get(note.Alias); exists && id != note.Id {
+			fmt.Printf("Alias %s already in use, not set.\n", note.Alias)
+			note.RemoveAlias()
+		} else {
+			aliases.Set(note.Alias, note.Id)
+			aliases.Write()
+		}
+	}
+
+	note.WriteData()
+	fmt.Println("Note " + note.Id.String() + " created.")
+
+	return
+}
+
+// Returns note content supplied by message or file option. If none
+// of them is given and stdin is not a terminal, stdin is read. Empty
+// input of stdin is ignored then, e.g. /dev/null of cron jobs.
+// hasContent is false, if the content must be written in the editor.
+func addContent(message string, file string) (content []byte, hasContent bool, err error) {
+	switch {
+	case message != "" && file != "":
+		err = errors.New("Options -m and -f are mutually exclusive")
+		return
+
+	case message != "":
+		content = []byte(message)
+
+	case file == "-":
+		content, err = io.ReadAll(os.Stdin)
+
+	case file != "":
+		content, err = os.ReadFile(file)
+
+	case stdinIsTerminal() == false:
+		content, err = io.ReadAll(os.Stdin)
+		if err != nil || len(bytes.TrimSpace(content)) == 0 {
+			return nil, false, err
+		}
+
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if len(bytes.TrimSpace(content)) == 0 {
+		err = errors.New("Note content is empty")
+		return
+	}
+
+	// always terminate content by new line
+	if content[len(content)-1] != '\n' {
+		content = append(content, '\n')
 	}
 
+	hasContent = true
 	return
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// use a file with content as stdin
+func testStdin(t *testing.T, content string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		f.Close()
+	})
+}
+
+func TestAddContent(t *testing.T) {
+	for _, tc := range []struct {
+		message, file, stdin string
+		content              string
+		hasContent           bool
+		err                  bool
+	}{
+		{"text", "", "", "text\n", true, false},
+		{"", "", "piped\n", "piped\n", true, false},
+		// e.g. /dev/null of cron jobs, the editor is started
+		{"", "", "", "", false, false},
+		{"", "", " \n", "", false, false},
+		{"", "-", "stdin", "stdin\n", true, false},
+		{"", "-", "", "", false, true},
+		{"text", "-", "", "", false, true},
+	} {
+		testStdin(t, tc.stdin)
+		content, hasContent, err := addContent(tc.message, tc.file)
+		if string(content) != tc.content || hasContent != tc.hasContent || (err != nil) != tc.err {
+			t.Errorf("addContent(%q, %q) with stdin %q = %q, %t, %v", tc.message, tc.file, tc.stdin, content, hasContent, err)
+		}
+	}
+}
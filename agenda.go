@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// notes due within this number of days are upcoming
+const agendaDays = 7
+
+// Due dates without a time of day are due until the end of the day
+func dueDeadline(due time.Time) time.Time {
+	local := due.Local()
+	if local.Hour() == 0 && local.Minute() == 0 && local.Second() == 0 {
+		return local.AddDate(0, 0, 1)
+	}
+	return local
+}
+
+// CMD: note [FILTER] agenda [-d DAYS]
+func agendaHandler(filter NoteFilter, args []string) (err error) {
+	var optHelp bool
+	var optDays int
+	fs := flag.NewFlagSet("note agenda", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteAgenda() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.IntVar(&optDays, "d", agendaDays, "Number of days to look ahead")
+	fs.IntVar(&optDays, "days", agendaDays, "Number of days to look ahead")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() > 0 {
+		helpNoteAgenda()
+	}
+
+	notes, err := notes(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	now := time.Now()
+	until := now.AddDate(0, 0, optDays)
+
+	var overdue, upcoming, review []Note
+	for _, n := range notes {
+		if n.DateDue.IsZero() == false {
+			deadline := dueDeadline(n.DateDue)
+			switch {
+			case now.After(deadline):
+				overdue = append(overdue, n)
+
+			case deadline.Before(until):
+				upcoming = append(upcoming, n)
+			}
+		}
+
+		if n.DateReview.IsZero() == false && n.DateReview.Before(until) {
+			review = append(review, n)
+		}
+	}
+
+	if len(overdue)+len(upcoming)+len(review) == 0 {
+		fmt.Println("Nothing on the agenda")
+		return
+	}
+
+	printAgenda("Overdue", overdue, func(n Note) time.Time { return n.DateDue })
+	printAgenda(fmt.Sprintf("Due within %d days", optDays), upcoming, func(n Note) time.Time { return n.DateDue })
+	printAgenda("Review", review, func(n Note) time.Time { return n.DateReview })
+
+	return
+}
+
+// display section of the agenda, sorted by date
+func printAgenda(title string, notes []Note, date func(n Note) time.Time) {
+	if len(notes) == 0 {
+		return
+	}
+
+	sort.SliceStable(notes, func(a int, b int) bool {
+		return date(notes[a]).Before(date(notes[b]))
+	})
+
+	fmt.Println(title + ":")
+	for _, n := range notes {
+		ts := date(n).Local()
+		format := notemanager.OutputTimeFormatShort
+		if ts.Hour() != 0 || ts.Minute() != 0 {
+			format = notemanager.OutputTimeFormatLong
+		}
+		fmt.Printf("  %-19s  %s  %s\n", ts.Format(format), n.ShortId(), n.Title)
+	}
+	fmt.Println()
+}
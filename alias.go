@@ -16,7 +16,7 @@ func editHandler(notes []Note, args []string) (err error) {
 	}
 	note := notes[0]
 
-	err = noteEditHandler(note)
+	err = noteEditHandler(note, 0)
 	if err != nil {
 		log.Fatal(err)
 	}
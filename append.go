@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// CMD: note FILTER append|prepend [OPTIONS] [TEXT|-]
+// mode is either append or prepend
+func appendHandler(filter NoteFilter, notes []Note, mode string, args []string) (err error) {
+	var optHelp bool
+	var optTimestamp bool
+	fs := flag.NewFlagSet("note "+mode, flag.ContinueOnError)
+	fs.Usage = func() { helpNoteAppend() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.BoolVar(&optTimestamp, "t", false, "Add timestamp header")
+	fs.BoolVar(&optTimestamp, "timestamp", false, "Add timestamp header")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp {
+		helpNoteAppend()
+	}
+
+	if err = requireSelection(filter, notes); err != nil {
+		Exit(err.Error())
+	}
+
+	text, err := appendText(fs.Args())
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	if optTimestamp {
+		text = "## " + time.Now().Format(notemanager.OutputTimeFormatLong) + "\n\n" + text
+	}
+
+	for _, n := range notes {
+		err = noteAppendHandler(n, mode, text)
+		if err != nil {
+			Exit(err.Error())
+		}
+	}
+
+	return
+}
+
+// Returns text to append. Text is either supplied by arguments, or read
+// from stdin, if the argument is - or no argument is given and stdin is
+// not a terminal.
+func appendText(args []string) (text string, err error) {
+	switch {
+	case len(args) == 1 && args[0] == "-", len(args) == 0 && stdinIsTerminal() == false:
+		var in []byte
+		in, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			return
+		}
+		text = string(in)
+
+	default:
+		text = strings.Join(args, " ")
+	}
+
+	if strings.TrimSpace(text) == "" {
+		err = errors.New("Missing text")
+		return
+	}
+
+	if strings.HasSuffix(text, "\n") == false {
+		text += "\n"
+	}
+
+	return
+}
+
+// Create a new version of note n with text added to the end, or
+// to the beginning of the latest version.
+func noteAppendHandler(n Note, mode string, text string) (err error) {
+	content := string(n.latestContent)
+	if content != "" && strings.HasSuffix(content, "\n") == false {
+		content += "\n"
+	}
+
+	switch mode {
+	case "append":
+		content += text
+
+	case "prepend":
+		content = text + content
+	}
+
+	err = os.WriteFile(n.tmpFile(), []byte(content), notemanager.FilePermission)
+	if err != nil {
+		return
+	}
+
+	err = commitNoteEdit(n, n.LatestVersion())
+	return
+}
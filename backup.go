@@ -0,0 +1,516 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// name of the manifest entry inside of a backup archive
+const backupManifestName = "manifest"
+
+// CMD: note backup FILE
+func backupHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note backup", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteBackup() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() != 1 {
+		helpNoteBackup()
+	}
+
+	manifest, err := writeBackup(fs.Arg(0))
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	fmt.Printf("Backup %s created. Files: %d\n", fs.Arg(0), len(manifest.Files))
+	return
+}
+
+// CMD: note restore-archive [OPTIONS] FILE
+func restoreArchiveHandler(args []string) (err error) {
+	var optHelp bool
+	var optMerge bool
+	var optCheck bool
+	fs := flag.NewFlagSet("note restore-archive", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteRestoreArchive() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.BoolVar(&optMerge, "m", false, "Merge archive into non-empty data directory")
+	fs.BoolVar(&optMerge, "merge", false, "Merge archive into non-empty data directory")
+	fs.BoolVar(&optCheck, "c", false, "Only verify the archive")
+	fs.BoolVar(&optCheck, "check", false, "Only verify the archive")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() != 1 {
+		helpNoteRestoreArchive()
+	}
+
+	// Extract into the tmp dir of the data directory, so restored
+	// files can be renamed into place on the same file system.
+	stage, err := os.MkdirTemp(notemanager.TempDir, "restore-")
+	if err != nil {
+		Exit(err.Error())
+	}
+	defer os.RemoveAll(stage)
+
+	manifest, err := extractBackup(fs.Arg(0), stage)
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	err = manifest.Verify(stage)
+	if err != nil {
+		Exit("Archive verification failed: " + err.Error())
+	}
+	fmt.Printf("Archive verified. Files: %d, created: %s\n", len(manifest.Files), manifest.Created.Local().Format(notemanager.OutputTimeFormatLong))
+
+	if optCheck {
+		return
+	}
+
+	if dataDirIsEmpty() == false && optMerge == false {
+		Exit("Data directory is not empty. Use --merge to merge the archive into it")
+	}
+
+	err = restoreBackup(stage)
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// Returns true if data directory neither contains notes nor aliases
+func dataDirIsEmpty() bool {
+	files, err := os.ReadDir(notemanager.NoteDir)
+	if err == nil && len(files) > 0 {
+		return false
+	}
+
+	return len(aliases) == 0
+}
+
+// Create a gzip compressed tar archive of the data directory.
+// The archive contains the notes with all versions and attachments,
+// the aliases file, the templates and a manifest with the sha1 checksum
+// of every file.
+func writeBackup(dst string) (manifest BackupManifest, err error) {
+	manifest = BackupManifest{
+		Version: 1,
+		Created: time.Now().UTC(),
+		Files:   make(map[string]string),
+	}
+
+	var files []string
+	err = filepath.WalkDir(notemanager.DataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(notemanager.DataDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			// temporary files are not part of the store
+			if path == notemanager.TempDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type().IsRegular() == false {
+			return nil
+		}
+
+		if isBackupPath(rel) == false {
+			return nil
+		}
+
+		sum, err := fileSha1(path)
+		if err != nil {
+			return err
+		}
+		manifest.Files[rel] = sum
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+
+	fh, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, notemanager.FilePermission)
+	if err != nil {
+		return
+	}
+	// no partial backup is left behind
+	defer func() {
+		fh.Close()
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	gw := gzip.NewWriter(fh)
+	tw := tar.NewWriter(gw)
+
+	// manifest comes first, so it can be read before the files
+	// it describes.
+	m, err := yaml.Marshal(manifest)
+	if err != nil {
+		return
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    backupManifestName,
+		Mode:    int64(notemanager.FilePermission),
+		Size:    int64(len(m)),
+		ModTime: manifest.Created,
+	})
+	if err != nil {
+		return
+	}
+	if _, err = tw.Write(m); err != nil {
+		return
+	}
+
+	for _, rel := range files {
+		err = addFileToTar(tw, filepath.Join(notemanager.DataDir, filepath.FromSlash(rel)), rel)
+		if err != nil {
+			return
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return
+	}
+	if err = gw.Close(); err != nil {
+		return
+	}
+	err = fh.Close()
+	return
+}
+
+// Checks if a path relative to the data directory belongs into a backup
+func isBackupPath(rel string) bool {
+	if rel == "aliases" {
+		return true
+	}
+	return strings.HasPrefix(rel, "notes/") || strings.HasPrefix(rel, "templates/")
+}
+
+// Write a single regular file to tar archive
+func addFileToTar(tw *tar.Writer, path string, name string) (err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return
+	}
+	hdr.Name = name
+
+	if err = tw.WriteHeader(hdr); err != nil {
+		return
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+
+	_, err = io.Copy(tw, fh)
+	return
+}
+
+// Extract backup archive src into directory dst and return its manifest.
+func extractBackup(src string, dst string) (manifest BackupManifest, err error) {
+	fh, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+
+	gr, err := gzip.NewReader(fh)
+	if err != nil {
+		return
+	}
+	defer gr.Close()
+
+	var hasManifest bool
+	tr := tar.NewReader(gr)
+	for {
+		var hdr *tar.Header
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if hdr.Name == backupManifestName {
+			var m []byte
+			m, err = io.ReadAll(tr)
+			if err != nil {
+				return
+			}
+			err = yaml.Unmarshal(m, &manifest)
+			if err != nil {
+				return
+			}
+			hasManifest = true
+			continue
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			err = errors.New("Invalid path in archive: " + hdr.Name)
+			return
+		}
+
+		path := filepath.Join(dst, name)
+		err = os.MkdirAll(filepath.Dir(path), notemanager.DirPermission)
+		if err != nil {
+			return
+		}
+
+		var out *os.File
+		out, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, os.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	if hasManifest == false {
+		err = errors.New("Archive does not contain a manifest")
+	}
+
+	return
+}
+
+// Verify checksums of extracted files in dir against the manifest.
+// Missing, unexpected and modified files are reported as error.
+func (m BackupManifest) Verify(dir string) (err error) {
+	var problems []string
+
+	for rel, sum := range m.Files {
+		actual, err := fileSha1(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			problems = append(problems, "missing "+rel)
+			continue
+		}
+		if actual != sum {
+			problems = append(problems, "checksum mismatch "+rel)
+		}
+	}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := m.Files[filepath.ToSlash(rel)]; ok == false {
+			problems = append(problems, "unexpected "+filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		err = errors.New(strings.Join(problems, ", "))
+	}
+
+	return
+}
+
+// Move verified files of an extracted backup from stage into the data
+// directory. Notes which already exist with the same data are skipped,
+// notes whose UUID collides with a different local note are restored
+// with a new UUID. Aliases and templates are only added if they do not
+// exist yet.
+func restoreBackup(stage string) (err error) {
+	var restored, skipped, renamed int
+
+	// old uuid => new uuid of notes restored with a new id
+	idMap := make(map[uuid.UUID]uuid.UUID)
+	// notes which have been moved into data directory
+	imported := make(map[uuid.UUID]bool)
+
+	err = os.MkdirAll(notemanager.NoteDir, notemanager.DirPermission)
+	if err != nil {
+		return
+	}
+
+	stageNotes := filepath.Join(stage, "notes")
+	files, err := os.ReadDir(stageNotes)
+	if err != nil && errors.Is(err, os.ErrNotExist) == false {
+		return
+	}
+
+	for _, file := range files {
+		id, err := uuid.Parse(file.Name())
+		if err != nil || file.IsDir() == false {
+			fmt.Printf("Skipping unknown entry notes/%s\n", file.Name())
+			continue
+		}
+
+		src := filepath.Join(stageNotes, file.Name())
+		dst := filepath.Join(notemanager.NoteDir, file.Name())
+
+		if DirExists(dst) {
+			local, _ := fileSha1(filepath.Join(dst, "data"))
+			archived, _ := fileSha1(filepath.Join(src, "data"))
+			if local == archived {
+				skipped++
+				continue
+			}
+
+			// UUID collision with a different note. Restore
+			// archived note under a new id.
+			newId := uuid.New()
+			err = rewriteNoteId(filepath.Join(src, "data"), newId)
+			if err != nil {
+				return err
+			}
+			idMap[id] = newId
+			dst = filepath.Join(notemanager.NoteDir, newId.String())
+			fmt.Printf("%s: UUID collision, restored as %s\n", id.String()[0:8], newId.String()[0:8])
+			renamed++
+			id = newId
+		}
+
+		err = os.Rename(src, dst)
+		if err != nil {
+			return err
+		}
+		imported[id] = true
+		restored++
+	}
+
+	// merge aliases
+	var archivedAliases NoteAliases
+	yml, err := os.ReadFile(filepath.Join(stage, "aliases"))
+	if err == nil {
+		err = yaml.Unmarshal(yml, &archivedAliases)
+		if err != nil {
+			return
+		}
+	}
+	err = nil
+
+	for alias, id := range archivedAliases {
+		if newId, ok := idMap[id]; ok {
+			id = newId
+		}
+
+		if existing, exists := aliases.Get(alias); exists {
+			if existing == id {
+				continue
+			}
+
+			fmt.Printf("Alias %s already points to %s, not restored for %s\n", alias, existing.String()[0:8], id.String()[0:8])
+			if imported[id] {
+				n, err := loadNote(id.String())
+				if err == nil {
+					n.RemoveAlias()
+					n.WriteData()
+				}
+			}
+			continue
+		}
+
+		aliases[alias] = id
+	}
+	aliases.Write()
+
+	// restore templates
+	stageTemplates := filepath.Join(stage, "templates")
+	templates, err := os.ReadDir(stageTemplates)
+	if err != nil && errors.Is(err, os.ErrNotExist) == false {
+		return
+	}
+	err = os.MkdirAll(notemanager.TemplateDir, notemanager.DirPermission)
+	if err != nil {
+		return
+	}
+	for _, t := range templates {
+		src := filepath.Join(stageTemplates, t.Name())
+		dst := filepath.Join(notemanager.TemplateDir, t.Name())
+		if _, err := os.Stat(dst); err == nil {
+			a, _ := fileSha1(src)
+			b, _ := fileSha1(dst)
+			if a != b {
+				fmt.Printf("Template %s already exists, skipped\n", t.Name())
+			}
+			continue
+		}
+		err = os.Rename(src, dst)
+		if err != nil {
+			return
+		}
+	}
+
+	fmt.Printf("Restored notes: %d, skipped identical: %d, new UUID: %d\n", restored, skipped, renamed)
+	return
+}
+
+// Set the id inside of a note data file
+func rewriteNoteId(path string, id uuid.UUID) (err error) {
+	yml, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var n Note
+	err = yaml.Unmarshal(yml, &n)
+	if err != nil {
+		return
+	}
+	n.Id = id
+
+	err = writeFileAtomic(path, n.Yaml(), notemanager.FilePermission)
+	return
+}
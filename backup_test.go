@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBackupRestore(t *testing.T) {
+	testDataDir(t)
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Backup",
+		Alias:       "plan",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000", "20260102-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "first\n", "second\n")
+	aliases.Set("plan", n.Id)
+	aliases.Write()
+	testWriteTemplate(t, "meeting.tmpl", "# {{ .Note.Title }}\n")
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	manifest, err := writeBackup(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := manifest.Files["notes/"+n.Id.String()+"/20260102-100000"]; ok == false {
+		t.Errorf("manifest files %v", manifest.Files)
+	}
+
+	// restore into a data directory, in which the UUID and the alias
+	// belong to another note
+	testDataDir(t)
+	local := Note{
+		Id:          n.Id,
+		Title:       "Local",
+		Alias:       "plan",
+		DateCreated: time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260201-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, local, "local\n")
+	aliases.Set("plan", local.Id)
+	aliases.Write()
+
+	stage, err := os.MkdirTemp(notemanager.TempDir, "restore-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err = extractBackup(archive, stage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = manifest.Verify(stage); err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreBackup(stage); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := notes(NoteFilter{IncludeDeleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("notes after restore: %d, want 2", len(all))
+	}
+	for _, x := range all {
+		switch x.Title {
+		case "Local":
+			if x.Id != local.Id || x.Alias != "plan" || string(x.latestContent) != "local\n" {
+				t.Errorf("local note changed: %+v", x)
+			}
+
+		case "Backup":
+			if x.Id == n.Id || x.Alias != "" || len(x.Versions) != 2 || string(x.latestContent) != "second\n" {
+				t.Errorf("restored note %+v", x)
+			}
+
+		default:
+			t.Errorf("unexpected note %+v", x)
+		}
+	}
+	if id, _ := aliases.Get("plan"); id != local.Id {
+		t.Errorf("alias plan points to %s, want %s", id, local.Id)
+	}
+	if _, err = loadTemplate("meeting"); err != nil {
+		t.Errorf("template not restored: %s", err)
+	}
+}
+
+func TestBackupVerifyModified(t *testing.T) {
+	testDataDir(t)
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Verify",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "content\n")
+
+	archive := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := writeBackup(archive); err != nil {
+		t.Fatal(err)
+	}
+
+	stage := t.TempDir()
+	manifest, err := extractBackup(archive, stage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := filepath.Join(stage, "notes", n.Id.String(), "20260101-100000")
+	if err = os.Chmod(version, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(version, []byte("modified\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = manifest.Verify(stage); err == nil {
+		t.Error("modified archive verified")
+	}
+}
@@ -0,0 +1,528 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosimple/conf"
+)
+
+// Option of the noterc. Keys are case insensitive.
+type ConfigOption struct {
+	Key         string
+	Description string
+	get         func(c *Config) string
+	set         func(c *Config, value string) error
+}
+
+// options of the noterc, in order of note config show
+var configOptions = []ConfigOption{
+	{
+		Key:         "datadir",
+		Description: "Directory of notes, templates and aliases",
+		get:         func(c *Config) string { return c.DataDir },
+		set: func(c *Config, v string) (err error) {
+			c.DataDir, err = parsePathOption(v)
+			return
+		},
+	},
+	{
+		Key:         "editor",
+		Description: "Text editor command line. {file} and {line} are replaced by the file and line to edit",
+		get:         func(c *Config) string { return c.Editor },
+		set: func(c *Config, v string) (err error) {
+			c.Editor, err = parseEditorOption(v)
+			return
+		},
+	},
+	{
+		Key:         "terminalReader",
+		Description: "Pager of note read",
+		get:         func(c *Config) string { return c.TerminalReader },
+		set: func(c *Config, v string) (err error) {
+			if v == "" {
+				return errors.New("must not be empty")
+			}
+			c.TerminalReader = filepath.Clean(v)
+			return
+		},
+	},
+	{
+		Key:         "fileManager",
+		Description: "Command to open attachments",
+		get:         func(c *Config) string { return c.FileManager },
+		set: func(c *Config, v string) (err error) {
+			if v == "" {
+				return errors.New("must not be empty")
+			}
+			c.FileManager = v
+			return
+		},
+	},
+	{
+		Key:         "versionTimeFormat",
+		Description: "Go time layout of note version file names",
+		get:         func(c *Config) string { return c.VersionTimeFormat },
+		set: func(c *Config, v string) (err error) {
+			c.VersionTimeFormat, err = parseVersionTimeFormatOption(v)
+			return
+		},
+	},
+	{
+		Key:         "outputTimeFormatShort",
+		Description: "Go time layout of dates in note lists",
+		get:         func(c *Config) string { return c.OutputTimeFormatShort },
+		set: func(c *Config, v string) (err error) {
+			c.OutputTimeFormatShort, err = parseTimeLayoutOption(v)
+			return
+		},
+	},
+	{
+		Key:         "outputTimeFormatLong",
+		Description: "Go time layout of timestamps",
+		get:         func(c *Config) string { return c.OutputTimeFormatLong },
+		set: func(c *Config, v string) (err error) {
+			c.OutputTimeFormatLong, err = parseTimeLayoutOption(v)
+			return
+		},
+	},
+	{
+		Key:         "filePermission",
+		Description: "Octal permission of note files",
+		get:         func(c *Config) string { return fmt.Sprintf("%04o", c.FilePermission) },
+		set: func(c *Config, v string) (err error) {
+			c.FilePermission, err = parsePermissionOption(v)
+			return
+		},
+	},
+	{
+		Key:         "filePermissionReadonly",
+		Description: "Octal permission of attachments",
+		get:         func(c *Config) string { return fmt.Sprintf("%04o", c.FilePermissionReadonly) },
+		set: func(c *Config, v string) (err error) {
+			c.FilePermissionReadonly, err = parsePermissionOption(v)
+			return
+		},
+	},
+	{
+		Key:         "dirPermission",
+		Description: "Octal permission of note directories",
+		get:         func(c *Config) string { return fmt.Sprintf("%04o", c.DirPermission) },
+		set: func(c *Config, v string) (err error) {
+			c.DirPermission, err = parsePermissionOption(v)
+			return
+		},
+	},
+	{
+		Key:         "markdown",
+		Description: "Render Markdown in read and print",
+		get:         func(c *Config) string { return strconv.FormatBool(c.RenderMarkdown) },
+		set: func(c *Config, v string) (err error) {
+			c.RenderMarkdown, err = strconv.ParseBool(v)
+			if err != nil {
+				err = errors.New("must be true or false")
+			}
+			return
+		},
+	},
+	{
+		Key:         "outputTemplate",
+		Description: "Template of the note header of read and print",
+		get:         func(c *Config) string { return c.OutputTemplate },
+		set: func(c *Config, v string) (err error) {
+			if reTemplateName.MatchString(v) == false {
+				return errors.New("invalid template name")
+			}
+			c.OutputTemplate = v
+			return
+		},
+	},
+	{
+		Key:         "tempFileExtension",
+		Description: "Extension of files opened in the editor, e.g. .md or .txt",
+		get:         func(c *Config) string { return c.TempFileExtension },
+		set: func(c *Config, v string) (err error) {
+			if v != "" && (reTempFileExtension.MatchString(v) == false || v == ".data") {
+				return errors.New("must be empty or a dot followed by letters or numbers")
+			}
+			c.TempFileExtension = v
+			return
+		},
+	},
+	{
+		Key:         "strict",
+		Description: "Abort if a note cannot be loaded",
+		get:         func(c *Config) string { return strconv.FormatBool(c.Strict) },
+		set: func(c *Config, v string) (err error) {
+			c.Strict, err = strconv.ParseBool(v)
+			if err != nil {
+				err = errors.New("must be true or false")
+			}
+			return
+		},
+	},
+	{
+		Key:         "serveToken",
+		Description: "Token of the API of note serve, clients send the header: Authorization: Bearer TOKEN",
+		get:         func(c *Config) string { return c.ServeToken },
+		set: func(c *Config, v string) (err error) {
+			if v != "" && (len(v) < 16 || strings.ContainsAny(v, " \t")) {
+				return errors.New("must have at least 16 characters and no white space")
+			}
+			c.ServeToken = v
+			return
+		},
+	},
+}
+
+// option selecting the notebook in use
+var notebookOption = ConfigOption{
+	Key:         "notebook",
+	Description: "Notebook in use, see note notebook",
+	get:         func(c *Config) string { return c.Notebook },
+	set: func(c *Config, v string) (err error) {
+		if reNotebookName.MatchString(v) == false {
+			return errors.New("invalid notebook name")
+		}
+		c.Notebook = v
+		return
+	},
+}
+
+// returns option by case insensitive key
+func configOption(key string) (o ConfigOption, err error) {
+	for _, o = range append(configOptions, notebookOption) {
+		if strings.EqualFold(o.Key, key) {
+			return
+		}
+	}
+
+	// data directories of notebooks: notebook.NAME.datadir
+	if m := reNotebookKey.FindStringSubmatch(strings.ToLower(key)); m != nil {
+		return notebookDataDirOption(m[1]), nil
+	}
+
+	err = errors.New("Unknown config key: " + key)
+	return
+}
+
+var reTempFileExtension = regexp.MustCompile(`^\.[A-Za-z0-9]+$`)
+
+// editor is a command line with arguments
+func parseEditorOption(v string) (string, error) {
+	args, err := splitCommandLine(v)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", errors.New("must not be empty")
+	}
+	return v, nil
+}
+
+// paths may start with ~ for the home directory
+func parsePathOption(v string) (string, error) {
+	if v == "" {
+		return "", errors.New("must not be empty")
+	}
+
+	if v == "~" || strings.HasPrefix(v, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		v = home + v[1:]
+	}
+
+	return filepath.Clean(v), nil
+}
+
+func parseTimeLayoutOption(v string) (string, error) {
+	// a layout without any element formats to itself
+	ref := time.Date(2001, 2, 3, 16, 5, 6, 0, time.UTC)
+	if v == "" || ref.Format(v) == v {
+		return "", errors.New("must be a Go time layout, e.g. 2006-01-02")
+	}
+	return v, nil
+}
+
+// Versions are parsed and sorted by name, so the layout must keep every
+// component down to seconds and later times must have greater names.
+func parseVersionTimeFormatOption(v string) (string, error) {
+	ref := time.Date(2001, 2, 3, 16, 5, 6, 0, time.UTC)
+	ts, err := time.Parse(v, ref.Format(v))
+	if err != nil || ts.Equal(ref) == false {
+		return "", errors.New("layout must contain date and time down to seconds")
+	}
+	if strings.ContainsAny(ref.Format(v), `/\:`) {
+		return "", errors.New("layout must be a valid file name")
+	}
+
+	// increasing times, each changing the width or the order of
+	// a component, e.g. day 9 to 10, 12 to 13 o'clock or a new year
+	times := []time.Time{
+		time.Date(2001, 9, 9, 9, 9, 9, 0, time.UTC),
+		time.Date(2001, 9, 9, 9, 9, 10, 0, time.UTC),
+		time.Date(2001, 9, 9, 9, 10, 0, 0, time.UTC),
+		time.Date(2001, 9, 9, 10, 0, 0, 0, time.UTC),
+		time.Date(2001, 9, 9, 12, 0, 0, 0, time.UTC),
+		time.Date(2001, 9, 9, 13, 0, 0, 0, time.UTC),
+		time.Date(2001, 9, 10, 0, 0, 0, 0, time.UTC),
+		time.Date(2001, 10, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2001, 12, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2099, 12, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for i := 1; i < len(times); i++ {
+		if times[i-1].Format(v) >= times[i].Format(v) {
+			return "", errors.New("layout must sort by time, i.e. year, month, day, hour, minute and second with leading zeros")
+		}
+	}
+
+	return v, nil
+}
+
+func parsePermissionOption(v string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || perm > 0777 {
+		return 0, errors.New("must be an octal permission, e.g. 0600")
+	}
+	return os.FileMode(perm), nil
+}
+
+// Returns the configuration. The defaults of the platform are overridden
+// by $VISUAL or $EDITOR, the noterc and the environment variables
+// NOTE_DATADIR and NOTE_EDITOR. The noterc is read from rcPath, if set
+// by --rc, or from the environment variable NOTERC. If notebook is set,
+// the data directory of the notebook is used.
+func parseConfig(rcPath string, notebook string) (c Config, err error) {
+	c = defaultConfig()
+
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if v := os.Getenv(env); v != "" {
+			c.Editor = v
+			break
+		}
+	}
+
+	explicit := true
+	switch {
+	case rcPath != "":
+		c.NotercPath = filepath.Clean(rcPath)
+
+	case os.Getenv("NOTERC") != "":
+		c.NotercPath = filepath.Clean(os.Getenv("NOTERC"))
+
+	default:
+		explicit = false
+	}
+
+	err = readNoterc(&c, explicit)
+	if err != nil {
+		return
+	}
+
+	// datadir is the data directory of the default notebook
+	if c.Notebooks == nil {
+		c.Notebooks = make(map[string]string)
+	}
+	c.Notebooks[defaultNotebook] = c.DataDir
+
+	// The notebook of --notebook is preferred over NOTE_DATADIR,
+	// which is preferred over the notebook of the noterc.
+	switch {
+	case notebook != "":
+
+	case os.Getenv("NOTE_DATADIR") != "":
+		c.Notebook = ""
+		if c.DataDir, err = parsePathOption(os.Getenv("NOTE_DATADIR")); err != nil {
+			return c, fmt.Errorf("NOTE_DATADIR: %s", err)
+		}
+
+	default:
+		notebook = c.Notebook
+	}
+
+	if notebook != "" {
+		dir, ok := c.Notebooks[strings.ToLower(notebook)]
+		if ok == false {
+			return c, errors.New("Unknown notebook: " + notebook)
+		}
+		c.Notebook = strings.ToLower(notebook)
+		c.DataDir = dir
+	}
+
+	if v := os.Getenv("NOTE_EDITOR"); v != "" {
+		if c.Editor, err = parseEditorOption(v); err != nil {
+			return c, fmt.Errorf("NOTE_EDITOR: %s", err)
+		}
+	}
+
+	c.AliasesPath = filepath.Clean(c.DataDir + "/aliases")
+	c.TemplateDir = filepath.Clean(c.DataDir + "/templates")
+	c.TempDir = filepath.Clean(c.DataDir + "/tmp")
+	c.NoteDir = filepath.Clean(c.DataDir + "/notes")
+
+	if c.Editor == "" {
+		err = errors.New("Please define a text editor")
+	}
+
+	return
+}
+
+// Apply options of the noterc to c. A missing noterc is only an error,
+// if it has been set explicitly.
+func readNoterc(c *Config, explicit bool) (err error) {
+	rc, err := conf.ReadFile(c.NotercPath)
+	if errors.Is(err, os.ErrNotExist) && explicit == false {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", c.NotercPath, err)
+	}
+
+	keys, _ := rc.Options("default")
+	for _, key := range keys {
+		o, err := configOption(key)
+		if err != nil {
+			return fmt.Errorf("%s: %s", c.NotercPath, err)
+		}
+
+		v, _ := rc.String("default", key)
+		err = o.set(c, v)
+		if err != nil {
+			return fmt.Errorf("%s: Invalid value of %s: %s", c.NotercPath, o.Key, err)
+		}
+	}
+
+	return
+}
+
+// Set option key to value in the noterc. Other lines and comments of the
+// file are kept.
+func writeNotercOption(path string, key string, value string) (err error) {
+	src, err := os.ReadFile(path)
+	if err != nil && errors.Is(err, os.ErrNotExist) == false {
+		return
+	}
+
+	reKey := regexp.MustCompile(`(?i)^\s*` + regexp.QuoteMeta(key) + `\s*[=:]`)
+	line := key + " = " + value
+
+	var lines []string
+	if len(src) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+	}
+
+	// options without section belong to the default section
+	section := "default"
+	end := len(lines)
+	replaced := false
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		if strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]") {
+			if section == "default" && end == len(lines) {
+				end = i
+			}
+			section = strings.ToLower(strings.TrimSpace(t[1 : len(t)-1]))
+			continue
+		}
+		if section == "default" && reKey.MatchString(l) {
+			lines[i] = line
+			replaced = true
+		}
+	}
+
+	if replaced == false {
+		lines = append(lines[:end], append([]string{line}, lines[end:]...)...)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return
+	}
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
+
+// CMD: note config [show|get KEY|set KEY VALUE]
+func configHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note config", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteConfig() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp {
+		helpNoteConfig()
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+
+	switch {
+	case args[0] == "show" && len(args) == 1:
+		fmt.Printf("# %s\n", notemanager.NotercPath)
+		for _, o := range configOptions {
+			fmt.Printf("%s = %s\n", o.Key, o.get(&notemanager))
+		}
+		for _, name := range notebookNames() {
+			if name != defaultNotebook {
+				fmt.Printf("notebook.%s.datadir = %s\n", name, notemanager.Notebooks[name])
+			}
+		}
+		if notemanager.Notebook != "" {
+			fmt.Printf("notebook = %s\n", notemanager.Notebook)
+		}
+
+	case args[0] == "get" && len(args) == 2:
+		var o ConfigOption
+		o, err = configOption(args[1])
+		if err == nil {
+			fmt.Println(o.get(&notemanager))
+		}
+
+	case args[0] == "set" && len(args) == 3:
+		err = configSetHandler(args[1], args[2])
+
+	default:
+		helpNoteConfig()
+	}
+
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// Validate value and write it to the noterc
+func configSetHandler(key string, value string) (err error) {
+	o, err := configOption(key)
+	if err != nil {
+		return
+	}
+
+	c := notemanager
+	if err = o.set(&c, value); err != nil {
+		return fmt.Errorf("Invalid value of %s: %s", o.Key, err)
+	}
+
+	err = writeNotercOption(notemanager.NotercPath, o.Key, value)
+	if err == nil {
+		fmt.Printf("%s = %s\n", o.Key, o.get(&c))
+	}
+	return
+}
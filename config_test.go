@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestParseVersionTimeFormatOption(t *testing.T) {
+	for layout, valid := range map[string]bool{
+		"20060102-150405":     true,
+		"2006-01-02_15-04-05": true,
+		"20060102T150405Z":    true,
+		"02012006-150405":     false,
+		"Jan _2 2006 150405":  false,
+		"060102-150405":       false,
+		"20060102-030405PM":   false,
+		"2006-1-2-150405":     false,
+		"20060102-1504":       false,
+		"2006-01-02 15:04:05": false,
+	} {
+		_, err := parseVersionTimeFormatOption(layout)
+		if (err == nil) != valid {
+			t.Errorf("parseVersionTimeFormatOption(%q) = %v, want valid %v", layout, err, valid)
+		}
+	}
+}
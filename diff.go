@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Split content into lines. A trailing new line does not create an
+// additional empty line.
+func splitLines(content []byte) []string {
+	s := string(content)
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Join lines to content, each line terminated by a new line.
+func joinLines(lines []string) []byte {
+	if len(lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// Calculate longest common subsequence of two slices of lines.
+// Returns for every line of a the index of the matching line in b,
+// or -1 if the line is not part of the common subsequence.
+func lcsMatches(a []string, b []string) (matches []int) {
+	// lengths[i][j] = length of lcs of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches = make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+
+		case j < len(b) && lengths[i+1][j] < lengths[i][j+1]:
+			j++
+
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+
+	return
+}
+
+// line of a diff. Op is ' ' for unchanged lines, '-' for lines only in
+// the old and '+' for lines only in the new version.
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// Returns the lines of a and b as diff, based on their longest common
+// subsequence.
+func diffLines(a []string, b []string) (diff []DiffLine) {
+	matches := lcsMatches(a, b)
+
+	j := 0
+	for i, line := range a {
+		if matches[i] == -1 {
+			diff = append(diff, DiffLine{'-', line})
+			continue
+		}
+		for ; j < matches[i]; j++ {
+			diff = append(diff, DiffLine{'+', b[j]})
+		}
+		diff = append(diff, DiffLine{' ', line})
+		j++
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{'+', b[j]})
+	}
+
+	return
+}
+
+// Three way merge of lines. Changes of mine and theirs against the
+// common base are combined. Regions changed differently on both sides
+// are marked with conflict markers. Returns the merged lines and the
+// number of conflicts.
+func merge3(base []string, mine []string, theirs []string, theirsLabel string) (merged []string, conflicts int) {
+	ma := lcsMatches(base, mine)
+	mb := lcsMatches(base, theirs)
+
+	i, j, k := 0, 0, 0
+	for i < len(base) || j < len(mine) || k < len(theirs) {
+		// line unchanged on both sides
+		if i < len(base) && ma[i] == j && mb[i] == k {
+			merged = append(merged, base[i])
+			i++
+			j++
+			k++
+			continue
+		}
+
+		// find next line of base which exists on both sides
+		ni, nj, nk := len(base), len(mine), len(theirs)
+		for x := i; x < len(base); x++ {
+			if ma[x] >= 0 && mb[x] >= 0 {
+				ni, nj, nk = x, ma[x], mb[x]
+				break
+			}
+		}
+
+		b, m, t := base[i:ni], mine[j:nj], theirs[k:nk]
+		switch {
+		case slices.Equal(m, b):
+			merged = append(merged, t...)
+
+		case slices.Equal(t, b), slices.Equal(m, t):
+			merged = append(merged, m...)
+
+		default:
+			conflicts++
+			merged = append(merged, "<<<<<<< yours")
+			merged = append(merged, m...)
+			merged = append(merged, "=======")
+			merged = append(merged, t...)
+			merged = append(merged, ">>>>>>> "+theirsLabel)
+		}
+
+		i, j, k = ni, nj, nk
+	}
+
+	return
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+func TestLcsMatches(t *testing.T) {
+	for _, tc := range []struct {
+		a, b    string
+		matches []int
+	}{
+		{"", "", []int{}},
+		{"a b c", "", []int{-1, -1, -1}},
+		{"a b c", "a b c", []int{0, 1, 2}},
+		{"a b c", "a x c", []int{0, -1, 2}},
+		{"a b c", "x a b", []int{1, 2, -1}},
+		{"a b a", "b a b", []int{-1, 0, 1}},
+	} {
+		matches := lcsMatches(strings.Fields(tc.a), strings.Fields(tc.b))
+		if slices.Equal(matches, tc.matches) == false {
+			t.Errorf("lcsMatches(%q, %q) = %v, want %v", tc.a, tc.b, matches, tc.matches)
+		}
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	for _, tc := range []struct {
+		base, mine, theirs string
+		merged             string
+		conflicts          int
+	}{
+		{"a b c", "a b c", "a b c", "a b c", 0},
+		{"a b c", "a x c", "a b c", "a x c", 0},
+		{"a b c", "a b c", "a b y", "a b y", 0},
+		{"a b c", "x b c", "a b y", "x b y", 0},
+		{"a b c", "a b c d", "z a b c", "z a b c d", 0},
+		{"a b c", "a c", "a c", "a c", 0},
+		{"a b c", "a x c", "a x c", "a x c", 0},
+		{"a b c", "a x c", "a y c", "a <<<<<<< x ======= y >>>>>>> v c", 1},
+	} {
+		merged, conflicts := merge3(strings.Fields(tc.base), strings.Fields(tc.mine), strings.Fields(tc.theirs), "v")
+		got := strings.ReplaceAll(strings.Join(merged, " "), "<<<<<<< yours", "<<<<<<<")
+		if got != tc.merged || conflicts != tc.conflicts {
+			t.Errorf("merge3(%q, %q, %q) = %q, %d, want %q, %d", tc.base, tc.mine, tc.theirs, got, conflicts, tc.merged, tc.conflicts)
+		}
+	}
+}
+
+func TestCommitConcurrentEditWithoutTerminal(t *testing.T) {
+	if stdinIsTerminal() {
+		t.Skip("stdin is a terminal")
+	}
+	testDataDir(t)
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Concurrent",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000", "20260102-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "a\nb\nc\n", "a\nb\ny\n")
+
+	// edit started from the first version
+	if err := os.WriteFile(n.tmpFile(), []byte("x\nb\nc\n"), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	if err := commitNoteEdit(n, "20260101-100000"); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := loadNote(n.Id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Versions) != 3 || string(merged.latestContent) != "x\nb\ny\n" {
+		t.Errorf("versions %v, latest content %q", merged.Versions, merged.latestContent)
+	}
+
+	// conflicting edits are not committed
+	if err = os.WriteFile(n.tmpFile(), []byte("a\nb\nz\n"), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	err = commitNoteEdit(merged, "20260101-100000")
+	if err == nil || strings.Contains(err.Error(), "conflict") == false {
+		t.Errorf("commitNoteEdit = %v, want conflict", err)
+	}
+	content, _ := os.ReadFile(n.tmpFile())
+	if string(content) != "a\nb\nz\n" {
+		t.Errorf("edit changed to %q", content)
+	}
+	if current, _ := loadNote(n.Id.String()); len(current.Versions) != 3 {
+		t.Errorf("versions %v", current.Versions)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Unsaved note content in the tmp directory. Drafts are left behind
+// if the editor or notemanager did not finish properly.
+type Draft struct {
+	// file name inside of tmp dir. Syntax: UUID[.PID][EXTENSION]
+	Name   string
+	NoteId uuid.UUID
+	// process which wrote the draft, 0 if the name has no PID
+	PID     int
+	ModTime time.Time
+	Size    int64
+}
+
+// returns path of draft file
+func (d Draft) Path() string {
+	return filepath.Clean(notemanager.TempDir + `/` + d.Name)
+}
+
+// returns path of the metadata file, which is written along
+// with drafts of new notes.
+func (d Draft) DataPath() string {
+	return filepath.Clean(notemanager.TempDir + `/` + d.NoteId.String() + `.data`)
+}
+
+// Draft of a note which has not been created yet
+func (d Draft) IsNew() bool {
+	return DirExists(filepath.Clean(notemanager.NoteDir+`/`+d.NoteId.String())) == false
+}
+
+// Returns metadata of a draft of a new note. If the metadata
+// file is missing, a note with a default title is returned.
+func (d Draft) Note() (n Note) {
+	yml, err := os.ReadFile(d.DataPath())
+	if err == nil {
+		yaml.Unmarshal(yml, &n)
+	}
+
+	n.Id = d.NoteId
+	if n.Title == "" {
+		n.Title = "Recovered draft"
+	}
+	return
+}
+
+// Returns the latest version of note n which existed, when the draft
+// was saved the last time. This is the version the draft is based on.
+func (d Draft) BaseVersion(n Note) (version string) {
+	version = n.Versions[0]
+	for _, v := range n.Versions {
+		ts, err := time.Parse(notemanager.VersionTimeFormat, v)
+		if err != nil || ts.After(d.ModTime) {
+			break
+		}
+		version = v
+	}
+	return
+}
+
+// returns the PID of the temporary file name UUID.PID[EXTENSION],
+// 0 if the name has none
+func tmpFilePid(name string) (pid int) {
+	if len(name) < 38 || name[36] != '.' {
+		return 0
+	}
+	pid, _ = strconv.Atoi(strings.SplitN(name[37:], ".", 2)[0])
+	return
+}
+
+// returns all drafts in tmp dir, sorted by modification time. Files
+// of running processes, e.g. of an editor opened by another note
+// command, are no drafts.
+func drafts() (ret []Draft, err error) {
+	files, err := os.ReadDir(notemanager.TempDir)
+	if err != nil {
+		return
+	}
+
+	for _, file := range files {
+		if file.Type().IsRegular() == false {
+			continue
+		}
+
+		name := file.Name()
+		if len(name) < 36 || strings.HasSuffix(name, ".data") {
+			continue
+		}
+
+		id, err := uuid.Parse(name[0:36])
+		if err != nil {
+			continue
+		}
+
+		pid := tmpFilePid(name)
+		if pid != 0 && processRunning(pid) {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		ret = append(ret, Draft{
+			Name:    name,
+			NoteId:  id,
+			PID:     pid,
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		})
+	}
+
+	sort.Slice(ret, func(a int, b int) bool {
+		return ret[a].ModTime.Before(ret[b].ModTime)
+	})
+
+	return
+}
+
+// returns drafts of a single note, excluding the temporary file
+// of the running process.
+func noteDrafts(id uuid.UUID) (ret []Draft) {
+	all, _ := drafts()
+	own := filepath.Base(Note{Id: id}.tmpFile())
+	for _, d := range all {
+		if d.NoteId == id && d.Name != own {
+			ret = append(ret, d)
+		}
+	}
+	return
+}
+
+// returns draft by name or unique prefix of name
+func draftByName(name string) (d Draft, err error) {
+	all, err := drafts()
+	if err != nil {
+		return
+	}
+
+	var found []Draft
+	for _, x := range all {
+		if x.Name == name {
+			return x, nil
+		}
+		if strings.HasPrefix(x.Name, name) {
+			found = append(found, x)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		err = errors.New("No such draft: " + name)
+
+	case 1:
+		d = found[0]
+
+	default:
+		err = errors.New("Multiple drafts found starting with " + name + ", use full name")
+	}
+
+	return
+}
+
+// CMD: note drafts [list|recover DRAFT|discard DRAFT]
+func draftsHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note drafts", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteDrafts() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp {
+		helpNoteDrafts()
+	}
+
+	args = fs.Args()
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "list":
+		err = listDrafts()
+
+	case "recover", "discard":
+		if len(args) != 2 {
+			helpNoteDrafts()
+		}
+
+		var d Draft
+		d, err = draftByName(args[1])
+		if err != nil {
+			Exit(err.Error())
+		}
+
+		if action == "recover" {
+			err = recoverDraft(d)
+		} else {
+			err = discardDraft(d)
+		}
+
+	default:
+		helpNoteDrafts()
+	}
+
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+func listDrafts() (err error) {
+	all, err := drafts()
+	if err != nil {
+		return
+	}
+
+	if len(all) == 0 {
+		fmt.Println("No drafts found")
+		return
+	}
+
+	for _, d := range all {
+		title := "(new) " + d.Note().Title
+		if d.IsNew() == false {
+			n, err := loadNote(d.NoteId.String())
+			if err == nil {
+				title = n.Title
+			}
+		}
+		fmt.Printf("%s  %s  %s (%d Bytes)\n", d.Name, d.ModTime.Local().Format(notemanager.OutputTimeFormatLong), title, d.Size)
+	}
+
+	return
+}
+
+// Save draft as a new version of its note, or create the note if
+// it does not exist.
+func recoverDraft(d Draft) (err error) {
+	if d.IsNew() {
+		return recoverNewNoteDraft(d)
+	}
+
+	n, err := loadNote(d.NoteId.String())
+	if err != nil {
+		return
+	}
+
+	content, err := os.ReadFile(d.Path())
+	if err != nil {
+		return
+	}
+	if bytes.Equal(content, n.latestContent) {
+		fmt.Printf("%s: Draft is identical to latest version, discarded.\n", n.ShortId())
+		return discardDraft(d)
+	}
+
+	// the draft becomes the temporary file of this process
+	err = os.Rename(d.Path(), n.tmpFile())
+	if err != nil {
+		return
+	}
+
+	err = commitNoteEdit(n, d.BaseVersion(n))
+	return
+}
+
+// Create a note from a draft of a new note.
+func recoverNewNoteDraft(d Draft) (err error) {
+	unlock, err := lockDataDir()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	n := d.Note()
+	version := d.ModTime.UTC().Format(notemanager.VersionTimeFormat)
+	n.Versions = []string{version}
+	if n.DateCreated.IsZero() {
+		n.DateCreated = d.ModTime.UTC()
+	}
+
+	// the draft becomes the temporary file of this process
+	err = os.Rename(d.Path(), n.tmpFile())
+	if err != nil {
+		return
+	}
+
+	err = n.moveTmpFile()
+	if err != nil {
+		return
+	}
+	n.WriteData()
+	os.Remove(d.DataPath())
+
+	fmt.Println("Note " + n.Id.String() + " created.")
+	return
+}
+
+// Remove draft
+func discardDraft(d Draft) (err error) {
+	err = os.Remove(d.Path())
+	if err != nil {
+		return
+	}
+
+	if d.IsNew() {
+		os.Remove(d.DataPath())
+	}
+
+	fmt.Printf("%s: Draft discarded.\n", d.Name)
+	return
+}
+
+// Offer recovery of drafts of new notes, which have been left behind
+// by a previous add command.
+func offerNewNoteDraftRecovery() {
+	all, _ := drafts()
+	for _, d := range all {
+		if d.IsNew() == false {
+			continue
+		}
+
+		prompt := fmt.Sprintf("Found unsaved draft of new note '%s' from %s. Recover it?", d.Note().Title, d.ModTime.Local().Format(notemanager.OutputTimeFormatLong))
+		if askYesNo(prompt) {
+			err := recoverDraft(d)
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
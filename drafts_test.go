@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// returns PID of a process which has exited
+func testExitedPid(t *testing.T) int {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+// write temporary file name with content to tmp dir
+func testWriteTmpFile(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(notemanager.TempDir, name)
+	if err := os.WriteFile(path, []byte(content), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDraftsSkipRunningProcesses(t *testing.T) {
+	testDataDir(t)
+
+	id := uuid.New()
+	orphan := fmt.Sprintf("%s.%d%s", id, testExitedPid(t), notemanager.TempFileExtension)
+	testWriteTmpFile(t, orphan, "orphan\n")
+	// opened in the editor of another note command
+	testWriteTmpFile(t, fmt.Sprintf("%s.%d%s", id, os.Getppid(), notemanager.TempFileExtension), "editing\n")
+	testWriteTmpFile(t, fmt.Sprintf("%s.%d%s", id, os.Getpid(), notemanager.TempFileExtension), "own\n")
+
+	all, err := drafts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Name != orphan {
+		t.Fatalf("drafts = %+v, want only %s", all, orphan)
+	}
+	if err = listDrafts(); err != nil {
+		t.Error(err)
+	}
+	if d, err := draftByName(id.String()); err != nil || d.Name != orphan {
+		t.Errorf("draftByName = %+v, %v", d, err)
+	}
+	if ds := noteDrafts(id); len(ds) != 1 {
+		t.Errorf("noteDrafts = %+v", ds)
+	}
+}
+
+func TestRecoverDraft(t *testing.T) {
+	testDataDir(t)
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Draft",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "saved\n")
+	path := testWriteTmpFile(t, fmt.Sprintf("%s.%d%s", n.Id, testExitedPid(t), notemanager.TempFileExtension), "unsaved\n")
+
+	d, err := draftByName(filepath.Base(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = recoverDraft(d); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := loadNote(n.Id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered.Versions) != 2 || string(recovered.latestContent) != "unsaved\n" {
+		t.Errorf("versions %v, latest content %q", recovered.Versions, recovered.latestContent)
+	}
+	if _, err = os.Stat(path); err == nil {
+		t.Errorf("draft %s not removed", path)
+	}
+}
+
+func TestRecoverNewNoteDraft(t *testing.T) {
+	testDataDir(t)
+
+	n := Note{Id: uuid.New(), Title: "New", DateCreated: time.Now().UTC()}
+	yml, _ := yaml.Marshal(n)
+	d := Draft{NoteId: n.Id}
+	if err := os.WriteFile(d.DataPath(), yml, notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	testWriteTmpFile(t, n.Id.String()+notemanager.TempFileExtension, "new\n")
+
+	d, err := draftByName(n.Id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IsNew() == false {
+		t.Fatal("draft of a new note is not new")
+	}
+	if err = recoverDraft(d); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := loadNote(n.Id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Title != "New" || string(created.latestContent) != "new\n" {
+		t.Errorf("created note %q with content %q", created.Title, created.latestContent)
+	}
+	if all, _ := drafts(); len(all) != 0 {
+		t.Errorf("drafts left: %+v", all)
+	}
+	if _, err = os.Stat(d.DataPath()); err == nil {
+		t.Error("metadata of draft not removed")
+	}
+}
+
+func TestDiscardDraft(t *testing.T) {
+	testDataDir(t)
+
+	id := uuid.New()
+	path := testWriteTmpFile(t, id.String()+notemanager.TempFileExtension, "discard\n")
+	d := Draft{NoteId: id}
+	if err := os.WriteFile(d.DataPath(), []byte("title: Discard\n"), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := draftByName(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = discardDraft(d); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{path, d.DataPath()} {
+		if _, err := os.Stat(p); err == nil {
+			t.Errorf("%s not removed", p)
+		}
+	}
+}
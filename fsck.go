@@ -0,0 +1,373 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// Result of a data integrity check
+type fsckResult struct {
+	Problems int
+	Repaired int
+}
+
+// report a problem of the store. prefix is usually the short id of a note.
+func (r *fsckResult) report(prefix string, msg string, repaired bool) {
+	r.Problems++
+	if repaired {
+		r.Repaired++
+		msg += " [repaired]"
+	}
+	fmt.Printf("%s: %s\n", prefix, msg)
+}
+
+// CMD: note fsck [--repair]
+func fsckHandler(args []string) (err error) {
+	var optHelp bool
+	var optRepair bool
+	var optQuarantine bool
+	fs := flag.NewFlagSet("note fsck", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteFsck() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.BoolVar(&optRepair, "r", false, "Repair problems where possible")
+	fs.BoolVar(&optRepair, "repair", false, "Repair problems where possible")
+	fs.BoolVar(&optQuarantine, "q", false, "Move notes which cannot be loaded to quarantine")
+	fs.BoolVar(&optQuarantine, "quarantine", false, "Move notes which cannot be loaded to quarantine")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() > 0 {
+		helpNoteFsck()
+	}
+
+	res := fsck(optRepair, optQuarantine)
+
+	fmt.Printf("---\nProblems: %d, repaired: %d\n", res.Problems, res.Repaired)
+	if res.Problems > res.Repaired {
+		Exit("Data directory has errors")
+	}
+
+	return
+}
+
+// Check integrity of the data directory. If repair is true, problems
+// which can be fixed without losing data are repaired. If quarantine is
+// true, entries of the note directory which still cannot be loaded are
+// moved into the quarantine directory.
+func fsck(repair bool, quarantine bool) (res fsckResult) {
+	files, err := os.ReadDir(notemanager.NoteDir)
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	// all valid notes by id
+	notes := make(map[uuid.UUID]Note)
+
+	for _, file := range files {
+		name := file.Name()
+		// hidden files, e.g. .DS_Store or .git, are ignored
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		id, err := uuid.Parse(name)
+		if err != nil || file.IsDir() == false {
+			res.report("notes/"+name, "Unknown entry in note directory", quarantine)
+			if quarantine {
+				quarantineNote(name)
+			}
+			continue
+		}
+
+		open := res.Problems - res.Repaired
+		n, ok := fsckNote(id, repair, &res)
+		if quarantine {
+			if _, err := loadNote(id.String()); err != nil {
+				// problems of the note are resolved by moving it away
+				open = res.Problems - res.Repaired - open
+				if open == 0 {
+					res.report(id.String()[0:8], "Cannot be loaded: "+err.Error(), true)
+				}
+				res.Repaired += open
+				quarantineNote(name)
+				continue
+			}
+		}
+		if ok {
+			notes[id] = n
+		}
+	}
+
+	fsckAliases(notes, repair, &res)
+	fsckTempDir(&res)
+
+	return
+}
+
+// Check a single note directory. Returns the loaded note and true,
+// if the data file could be parsed.
+func fsckNote(id uuid.UUID, repair bool, res *fsckResult) (n Note, ok bool) {
+	prefix := id.String()[0:8]
+	path := filepath.Join(notemanager.NoteDir, id.String())
+
+	yml, err := os.ReadFile(filepath.Join(path, "data"))
+	if err != nil {
+		res.report(prefix, "Missing data file", false)
+		return
+	}
+
+	err = yaml.Unmarshal(yml, &n)
+	if err != nil {
+		res.report(prefix, "Data file cannot be parsed: "+err.Error(), false)
+		return
+	}
+	ok = true
+
+	var changed bool
+
+	if n.Id != id {
+		res.report(prefix, "Note id in data file does not match directory: "+n.Id.String(), repair)
+		n.Id = id
+		if repair {
+			n.WriteData()
+		}
+	}
+
+	// versions listed in data file must exist on disk
+	var versions []string
+	var missing []string
+	var duplicates []string
+	for _, v := range n.Versions {
+		if slices.Contains(versions, v) || slices.Contains(missing, v) {
+			duplicates = append(duplicates, v)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(path, v)); err != nil {
+			missing = append(missing, v)
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	// version files on disk must be listed in data file
+	var orphans []string
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		res.report(prefix, err.Error(), false)
+		return
+	}
+	for _, e := range entries {
+		switch {
+		case e.Name() == "data":
+			continue
+
+		case e.Name() == "attachments" && e.IsDir():
+			continue
+
+		case isVersionName(e.Name()) && e.Type().IsRegular():
+			if slices.Contains(n.Versions, e.Name()) == false {
+				orphans = append(orphans, e.Name())
+				versions = append(versions, e.Name())
+			}
+
+		default:
+			res.report(prefix, "Unknown file "+e.Name(), false)
+		}
+	}
+	sort.Strings(versions)
+
+	// a note without any version cannot be repaired
+	fixable := repair && len(versions) > 0
+	if len(versions) == 0 {
+		res.report(prefix, "Note has no versions", false)
+	}
+	for _, v := range missing {
+		res.report(prefix, "Missing version file "+v, fixable)
+	}
+	for _, v := range orphans {
+		res.report(prefix, "Orphan version file "+v, fixable)
+	}
+	for _, v := range duplicates {
+		res.report(prefix, "Duplicate version entry "+v, fixable)
+	}
+	if len(missing) > 0 || len(orphans) > 0 || len(duplicates) > 0 {
+		changed = true
+	}
+
+	// compare attachment checksums
+	attachmentDir := filepath.Join(path, "attachments")
+	for _, a := range n.Attachments {
+		sum, err := fileSha1(filepath.Join(attachmentDir, a.Filename))
+		if err != nil {
+			res.report(prefix, "Missing attachment "+a.Filename, false)
+			continue
+		}
+		if sum != a.Sha1 {
+			res.report(prefix, "Checksum mismatch of attachment "+a.Filename, false)
+		}
+	}
+	attachments, err := os.ReadDir(attachmentDir)
+	if err != nil && errors.Is(err, os.ErrNotExist) == false {
+		res.report(prefix, err.Error(), false)
+	}
+	for _, e := range attachments {
+		known := slices.ContainsFunc(n.Attachments, func(a Attachment) bool {
+			return a.Filename == e.Name()
+		})
+		if known == false {
+			res.report(prefix, "Unknown attachment "+e.Name(), false)
+		}
+	}
+
+	if fixable && changed {
+		n.Versions = versions
+		n.WriteData()
+	}
+
+	return
+}
+
+// Check that aliases point to existing notes and the alias of
+// every note agrees with the aliases file.
+func fsckAliases(notes map[uuid.UUID]Note, repair bool, res *fsckResult) {
+	var changed bool
+
+	keys := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		keys = append(keys, alias)
+	}
+	sort.Strings(keys)
+
+	for _, alias := range keys {
+		id := aliases[alias]
+		if DirExists(filepath.Join(notemanager.NoteDir, id.String())) == false {
+			res.report("aliases", fmt.Sprintf("Alias %s points to missing note %s", alias, id.String()[0:8]), repair)
+			if repair {
+				aliases.Delete(alias)
+				changed = true
+			}
+			continue
+		}
+
+		n, ok := notes[id]
+		if ok && n.Alias != alias {
+			res.report(n.ShortId(), fmt.Sprintf("Alias in data file is '%s', aliases file has '%s'", n.Alias, alias), repair)
+			if repair {
+				n.SetAlias(alias)
+				n.WriteData()
+				notes[id] = n
+			}
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(notes))
+	for id := range notes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a int, b int) bool {
+		return ids[a].String() < ids[b].String()
+	})
+
+	for _, id := range ids {
+		n := notes[id]
+		if n.Alias == "" {
+			continue
+		}
+		id, exists := aliases.Get(n.Alias)
+		if exists && id == n.Id {
+			continue
+		}
+
+		if exists {
+			res.report(n.ShortId(), fmt.Sprintf("Alias %s belongs to note %s", n.Alias, id.String()[0:8]), repair)
+			if repair {
+				n.RemoveAlias()
+				n.WriteData()
+			}
+			continue
+		}
+
+		res.report(n.ShortId(), fmt.Sprintf("Alias %s missing in aliases file", n.Alias), repair)
+		if repair {
+			aliases[n.Alias] = n.Id
+			changed = true
+		}
+	}
+
+	if changed {
+		aliases.Write()
+	}
+}
+
+// Move an entry of the note directory into the quarantine directory,
+// where it is ignored by all commands but can be inspected manually.
+func quarantineNote(name string) {
+	dir := filepath.Join(notemanager.DataDir, "quarantine")
+	err := os.MkdirAll(dir, notemanager.DirPermission)
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	dst := filepath.Join(dir, name)
+	if _, err := os.Stat(dst); err == nil {
+		dst += "." + time.Now().UTC().Format(notemanager.VersionTimeFormat)
+	}
+
+	err = os.Rename(filepath.Join(notemanager.NoteDir, name), dst)
+	if err != nil {
+		Exit(err.Error())
+	}
+	fmt.Printf("notes/%s: Moved to %s\n", name, dst)
+}
+
+// Checks if name is the name of a version file
+func isVersionName(name string) bool {
+	_, err := time.Parse(notemanager.VersionTimeFormat, name)
+	return err == nil
+}
+
+// Report leftover files in tmp dir
+func fsckTempDir(res *fsckResult) {
+	files, err := os.ReadDir(notemanager.TempDir)
+	if err != nil {
+		res.report("tmp", err.Error(), false)
+		return
+	}
+
+	all, err := drafts()
+	if err != nil {
+		res.report("tmp", err.Error(), false)
+		return
+	}
+	isDraft := make(map[string]bool)
+	for _, d := range all {
+		isDraft[d.Name] = true
+	}
+
+	for _, file := range files {
+		if isDraft[file.Name()] {
+			res.report("tmp/"+file.Name(), "Unsaved draft. Recover or discard it with 'note drafts'", false)
+			continue
+		}
+		// opened in the editor of a running note command
+		if pid := tmpFilePid(file.Name()); pid != 0 && processRunning(pid) {
+			continue
+		}
+		if strings.HasSuffix(file.Name(), ".data") {
+			continue
+		}
+		res.report("tmp/"+file.Name(), "Leftover temporary file", false)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFsckVersionTimeFormat(t *testing.T) {
+	testDataDir(t)
+	notemanager.VersionTimeFormat = "2006-01-02_15-04-05"
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Layout",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"2026-01-01_10-00-00", "2026-01-02_10-00-00"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "first\n", "second\n")
+
+	if res := fsck(false, false); res.Problems != 0 {
+		t.Errorf("%d problems in a valid store", res.Problems)
+	}
+
+	// a version file missing in the data file is an orphan
+	orphan := filepath.Join(n.Path(), "2026-01-03_10-00-00")
+	if err := os.WriteFile(orphan, []byte("third\n"), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	if res := fsck(true, false); res.Problems != 1 || res.Repaired != 1 {
+		t.Errorf("orphan version: %+v", res)
+	}
+}
+
+func TestFsckTempDir(t *testing.T) {
+	testDataDir(t)
+
+	id := uuid.New()
+	testWriteTmpFile(t, fmt.Sprintf("%s.%d%s", id, testExitedPid(t), notemanager.TempFileExtension), "draft\n")
+	testWriteTmpFile(t, fmt.Sprintf("%s.%d%s", id, os.Getppid(), notemanager.TempFileExtension), "editing\n")
+	testWriteTmpFile(t, "leftover", "")
+
+	// the draft and the leftover file, the file in use is no problem
+	if res := fsck(false, false); res.Problems != 2 {
+		t.Errorf("%d problems, want 2", res.Problems)
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/google/uuid"
 	"golang.org/x/exp/slices"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v3"
 )
 
@@ -39,28 +40,43 @@ Version:      %s
 	log.Fatal(Autobreak(x))
 }
 
+// Returns an error, unless notes are selected by ID or alias, or the
+// filter matches exactly one note. Guards commands, which would modify
+// every note if no filter is given.
+func requireSelection(filter NoteFilter, notes []Note) error {
+	switch {
+	case len(notes) == 0:
+		return errors.New("No note selected")
+
+	case len(filter.Notes) == 0 && len(notes) > 1:
+		return fmt.Errorf("Filter matches %d notes, select the notes by ID or alias", len(notes))
+	}
+
+	return nil
+}
+
 // parse Command for FILTER arguments.
 // i.e. +tag, -tag, created.after, created.before,
 // modified.after, modified.before etc.
 func parseFilter(args []string) (filter NoteFilter, rargs []string, err error) {
 	for k, v := range args {
 		// match +somestring tag
-		r := regexp.MustCompile(`^\+[\pL0-9]+$`)
-		if r.MatchString(v) {
-			if slices.Contains(filter.TagsInclude, v[1:]) {
+		if strings.HasPrefix(v, "+") && reTag.MatchString(normalizeTag(v[1:])) {
+			tag := normalizeTag(v[1:])
+			if slices.Contains(filter.TagsInclude, tag) {
 				continue
 			}
-			filter.TagsInclude = append(filter.TagsInclude, v[1:])
+			filter.TagsInclude = append(filter.TagsInclude, tag)
 			continue
 		}
 
 		// match -somestring tag
-		r = regexp.MustCompile(`^\-[\pL0-9]+$`)
-		if r.MatchString(v) {
-			if slices.Contains(filter.TagsExclude, v[1:]) {
+		if strings.HasPrefix(v, "-") && reTag.MatchString(normalizeTag(v[1:])) {
+			tag := normalizeTag(v[1:])
+			if slices.Contains(filter.TagsExclude, tag) {
 				continue
 			}
-			filter.TagsExclude = append(filter.TagsExclude, v[1:])
+			filter.TagsExclude = append(filter.TagsExclude, tag)
 			continue
 		}
 
@@ -76,7 +92,7 @@ func parseFilter(args []string) (filter NoteFilter, rargs []string, err error) {
 				ts := v[14:]
 				filter.CreatedAfter, err = parseTimestamp(ts)
 				if err != nil {
-					log.Fatalf("Timestamp parsing failed: %s", ts)
+					return filter, rargs, fmt.Errorf("Timestamp parsing failed: %s", ts)
 				}
 				continue
 			}
@@ -88,7 +104,7 @@ func parseFilter(args []string) (filter NoteFilter, rargs []string, err error) {
 				ts := v[15:]
 				filter.CreatedBefore, err = parseTimestamp(ts)
 				if err != nil {
-					log.Fatalf("Timestamp parsing failed: %s", ts)
+					return filter, rargs, fmt.Errorf("Timestamp parsing failed: %s", ts)
 				}
 				continue
 			}
@@ -100,7 +116,7 @@ func parseFilter(args []string) (filter NoteFilter, rargs []string, err error) {
 				ts := v[15:]
 				filter.ModifiedAfter, err = parseTimestamp(ts)
 				if err != nil {
-					log.Fatalf("Timestamp parsing failed: %s", ts)
+					return filter, rargs, fmt.Errorf("Timestamp parsing failed: %s", ts)
 				}
 				continue
 			}
@@ -112,21 +128,31 @@ func parseFilter(args []string) (filter NoteFilter, rargs []string, err error) {
 				ts := v[16:]
 				filter.ModifiedBefore, err = parseTimestamp(ts)
 				if err != nil {
-					log.Fatalf("Timestamp parsing failed: %s", ts)
+					return filter, rargs, fmt.Errorf("Timestamp parsing failed: %s", ts)
 				}
 				continue
 			}
 		}
 
+		// user defined attribute, e.g. project:apollo or status.not:done
+		if m := reAttribute.FindStringSubmatch(v); m != nil && slices.Contains(reservedAttributes, m[1]) == false {
+			filter.Attributes = append(filter.Attributes, AttributeFilter{
+				Key:    m[1],
+				Value:  m[3],
+				Negate: m[2] != "",
+			})
+			continue
+		}
+
 		// try Note ID
 		if len(v) == 36 {
 			if _, err := uuid.Parse(v); err != nil {
-				Exit("Invalid UUID syntax: " + v)
+				return filter, rargs, errors.New("Invalid UUID syntax: " + v)
 			}
 
 			n, err := loadNote(v)
 			if err != nil {
-				Exit(err.Error())
+				return filter, rargs, err
 			}
 
 			filter.Notes = append(filter.Notes, n.Id.String())
@@ -137,12 +163,12 @@ func parseFilter(args []string) (filter NoteFilter, rargs []string, err error) {
 		if isUuidAbbr(v) {
 			id, err := uuidByAbbr(v)
 			if err != nil {
-				Exit(`No such note: ` + v)
+				return filter, rargs, errors.New(`No such note: ` + v)
 			}
 
 			n, err := loadNote(id.String())
 			if err != nil {
-				Exit(err.Error())
+				return filter, rargs, err
 			}
 			filter.Notes = append(filter.Notes, n.Id.String())
 			continue
@@ -165,7 +191,7 @@ func parseFilter(args []string) (filter NoteFilter, rargs []string, err error) {
 			IncludeDeleted: filter.IncludeDeleted,
 		}
 		if reflect.DeepEqual(filter, cmp) == false {
-			Exit("Note IDs and filter terms supplied, but they are mutually exclusive")
+			err = errors.New("Note IDs and filter terms supplied, but they are mutually exclusive")
 		}
 	}
 
@@ -268,38 +294,191 @@ func parseTagModifiers(args []string) (posTags []string, negTags []string, rargs
 	return
 }
 
-// Open the Editor and edit file filepath
-func runEditor(filepath string) (err error) {
-	cmd := exec.Command(notemanager.Editor, filepath)
+// parse command for leading attribute modifiers, i.e. key:value or key:.
+// key: removes the attribute and is only accepted for the known keys,
+// otherwise it is taken as text, e.g. of the title "re: meeting".
+func parseAttributeModifiers(args []string, known []string) (attributes map[string]string, rargs []string) {
+	attributes = make(map[string]string)
+	rargs = args
+	for len(rargs) > 0 {
+		m := reAttribute.FindStringSubmatch(rargs[0])
+		if m == nil || m[2] != "" {
+			break
+		}
+		if m[3] == "" && slices.Contains(known, m[1]) == false {
+			break
+		}
+		attributes[m[1]] = m[3]
+		rargs = rargs[1:]
+	}
+
+	return
+}
+
+// returns the attribute keys, which can be removed by key:, i.e. the
+// dates and the user defined attributes of notes
+func attributeKeys(notes []Note) (keys []string) {
+	keys = slices.Clone(dateAttributes)
+	for _, n := range notes {
+		for k := range n.Attributes {
+			if slices.Contains(keys, k) == false {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return
+}
+
+// Open the Editor and edit file filepath. If line is greater than 0,
+// the editor opens the file at line, if the editor setting has a {line}
+// placeholder.
+func runEditor(filepath string, line int) (err error) {
+	args, err := editorArgs(notemanager.Editor, filepath, line)
+	if err != nil {
+		return
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
 
 	err = cmd.Run()
 	return
 }
 
-// returns a list of slice of Notes matching the filter
+// Returns the arguments of the editor command line with the placeholders
+// {file} and {line} replaced. Without {file}, the file is appended.
+func editorArgs(editor string, file string, line int) (args []string, err error) {
+	args, err = splitCommandLine(editor)
+	if err != nil {
+		return
+	}
+	if len(args) == 0 {
+		return nil, errors.New("Please define a text editor")
+	}
+
+	if line < 1 {
+		line = 1
+	}
+
+	hasFile := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{file}") {
+			hasFile = true
+		}
+		arg = strings.ReplaceAll(arg, "{file}", file)
+		args[i] = strings.ReplaceAll(arg, "{line}", strconv.Itoa(line))
+	}
+
+	if hasFile == false {
+		args = append(args, file)
+	}
+
+	return
+}
+
+// Quote s, so it is a single argument of a command line
+func quoteCommandArg(s string) string {
+	if strings.ContainsAny(s, " \t\"'") == false {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// Split command line s into arguments. Arguments are separated by white
+// space, which can be kept in single or double quotes. A backslash
+// escapes a following quote, backslash or white space, otherwise it is
+// kept, so Windows paths do not need to be escaped.
+func splitCommandLine(s string) (args []string, err error) {
+	var arg strings.Builder
+	var quote rune
+	inArg := false
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && quote != '\'' && i+1 < len(runes) && strings.ContainsRune(" \t\"'\\", runes[i+1]):
+			i++
+			arg.WriteRune(runes[i])
+			inArg = true
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("Unterminated quote in command line: " + s)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return
+}
+
+// errors of notes which could not be loaded, by name of note directory
+var noteErrors = make(map[string]error)
+
+// returns a list of slice of Notes matching the filter.
+// Notes which cannot be loaded are skipped and collected in noteErrors,
+// unless strict mode is enabled.
 func notes(filter NoteFilter) (notes []Note, err error) {
 	files, err := os.ReadDir(notemanager.NoteDir)
 	if err != nil {
-		log.Fatal(err)
+		return
 	}
 
 	for _, file := range files {
+		// ignore hidden files, e.g. .DS_Store or .git
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+
 		if file.IsDir() == false {
 			continue
 		}
 
-		noteId := uuid.MustParse(file.Name())
+		noteId, err := uuid.Parse(file.Name())
+		if err != nil {
+			if err = noteLoadFailed(file.Name(), errors.New("Directory name is not a note id")); err != nil {
+				return nil, err
+			}
+			continue
+		}
 
 		note, err := loadNote(noteId.String())
 		if err != nil {
-			log.Fatal(err)
+			if err = noteLoadFailed(file.Name(), err); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		matches, err := note.MatchesFilter(filter)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		if matches {
 			notes = append(notes, note)
@@ -309,6 +488,35 @@ func notes(filter NoteFilter) (notes []Note, err error) {
 	return
 }
 
+// record a note which could not be loaded. In strict mode the error is
+// returned instead.
+func noteLoadFailed(name string, err error) error {
+	if notemanager.Strict {
+		return fmt.Errorf("notes/%s: %s", name, err)
+	}
+	noteErrors[name] = err
+	return nil
+}
+
+// print summary of notes which could not be loaded to stderr
+func warnNoteErrors() {
+	if len(noteErrors) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(noteErrors))
+	for name := range noteErrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Warning: Skipped %d note(s) which could not be loaded. Run 'note fsck' for details.\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  notes/%s: %s\n", name, noteErrors[name])
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
 func sortNotes(notes []Note) (ret []Note, err error) {
 	// sort notes by DateCreated ASC
 	sort.Slice(notes, func(a int, b int) bool {
@@ -393,7 +601,8 @@ func Autobreak(x string) (ret string) {
 	var width int
 	width = terminalWidth()
 
-	if width > 72 {
+	// width is unknown, if not attached to a terminal
+	if width <= 0 || width > 72 {
 		width = 72
 	}
 
@@ -442,17 +651,6 @@ func Autobreak(x string) (ret string) {
 	return
 }
 
-// moves temporary note from tempDir to specific note directory inside noteDir
-func moveFile(id string, version string) (err error) {
-	oldFile := filepath.Clean(fmt.Sprintf("%s/%s", notemanager.TempDir, id))
-	newFile := filepath.Clean(fmt.Sprintf("%s/%s/%s", notemanager.NoteDir, id, version))
-
-	os.Mkdir(filepath.Clean(fmt.Sprintf("%s/%s", notemanager.NoteDir, id)), notemanager.DirPermission)
-	err = os.Rename(oldFile, newFile)
-
-	return
-}
-
 func readMetadataFile(id string) (metadata Metadata, err error) {
 	metadataRaw, err := os.ReadFile(filepath.Clean(notemanager.NoteDir + "/" + id + "/meta"))
 	if err != nil {
@@ -528,6 +726,15 @@ func DirExists(dir string) bool {
 	return true
 }
 
+// Returns true if stdin is connected to a terminal
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
 func askYesNo(prompt string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s yes/No: ", prompt)
@@ -551,18 +758,103 @@ func initDataDir() error {
 	}
 	for _, f := range folders {
 		fmt.Println(`Creating directory ` + f)
-		err := os.Mkdir(f, 0700)
+		err := os.MkdirAll(f, notemanager.DirPermission)
 		if err != nil {
 			return err
 		}
 	}
 
 	fmt.Println(`Creating file ` + notemanager.AliasesPath)
-	f, err := os.OpenFile(notemanager.AliasesPath, os.O_RDONLY|os.O_CREATE, 0600)
+	f, err := os.OpenFile(notemanager.AliasesPath, os.O_RDONLY|os.O_CREATE, notemanager.FilePermission)
 	if err != nil {
 		return err
 	}
 	return f.Close()
+}
 
-	return nil
+// returned by lockFile if the lock is held by another process
+var errLocked = errors.New("Data directory is locked by another process")
+
+// lock file of data directory, while the lock is held
+var dataDirLock *os.File
+
+// Acquire the advisory lock of the data directory. Commands which modify
+// the store must hold the lock while reading and writing note data, so
+// concurrent processes do not overwrite each others changes. Waits until
+// the lock is released by other processes. The lock is released by the
+// returned function or when the process exits.
+func lockDataDir() (unlock func(), err error) {
+	unlock = func() {}
+
+	// already held by this process
+	if dataDirLock != nil {
+		return
+	}
+
+	f, err := lockDir(notemanager.DataDir)
+	if err != nil {
+		return
+	}
+
+	dataDirLock = f
+	unlock = func() {
+		dataDirLock = nil
+		f.Close()
+	}
+	return
+}
+
+// Acquire the advisory lock of data directory dir, e.g. of another
+// notebook. The lock is released by closing the returned file.
+func lockDir(dir string) (f *os.File, err error) {
+	f, err = os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, notemanager.FilePermission)
+	if err != nil {
+		return
+	}
+
+	err = lockFile(f, false)
+	if err == errLocked {
+		fmt.Fprintln(os.Stderr, "Waiting for lock of data directory...")
+		err = lockFile(f, true)
+	}
+	if err != nil {
+		f.Close()
+		f = nil
+	}
+	return
+}
+
+// Write data to file atomically. Data is written to a temporary file in
+// the same directory which replaces the target after it has been synced
+// to disk. Readers see either the old or the new content, but never a
+// partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return
+	}
+	tmp := f.Name()
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(tmp, perm); err != nil {
+		return
+	}
+
+	err = os.Rename(tmp, path)
+	return
 }
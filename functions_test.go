@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+func TestParseFilter(t *testing.T) {
+	testDataDir(t)
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Filter",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "content\n")
+
+	filter, rargs, err := parseFilter([]string{"+work", "-done", "project:apollo", "list", "+ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(filter.TagsInclude, []string{"work"}) == false || slices.Equal(filter.TagsExclude, []string{"done"}) == false {
+		t.Errorf("tags %v, %v", filter.TagsInclude, filter.TagsExclude)
+	}
+	if len(filter.Attributes) != 1 || filter.Attributes[0] != (AttributeFilter{Key: "project", Value: "apollo"}) {
+		t.Errorf("attributes %v", filter.Attributes)
+	}
+	if slices.Equal(rargs, []string{"list", "+ignored"}) == false {
+		t.Errorf("remaining args %v", rargs)
+	}
+
+	filter, _, err = parseFilter([]string{n.ShortId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(filter.Notes, []string{n.Id.String()}) == false || filter.IncludeDeleted == false {
+		t.Errorf("filter by short id %+v", filter)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"created.after:never"}, "Timestamp parsing failed"},
+		{[]string{"modified.before:never"}, "Timestamp parsing failed"},
+		{[]string{strings.Repeat("x", 36)}, "Invalid UUID syntax"},
+		{[]string{"abcdef12"}, "No such note"},
+		{[]string{n.Id.String(), "+work"}, "mutually exclusive"},
+	} {
+		_, _, err := parseFilter(tc.args)
+		if err == nil || strings.Contains(err.Error(), tc.err) == false {
+			t.Errorf("parseFilter(%q) = %v, want error %q", tc.args, err, tc.err)
+		}
+	}
+}
+
+func TestParseAttributeModifiers(t *testing.T) {
+	known := []string{"due", "project"}
+	for _, tc := range []struct {
+		args       []string
+		attributes map[string]string
+		rargs      []string
+	}{
+		{[]string{"project:apollo", "status:open", "Title"}, map[string]string{"project": "apollo", "status": "open"}, []string{"Title"}},
+		{[]string{"project:", "due:"}, map[string]string{"project": "", "due": ""}, nil},
+		{[]string{"re:", "meeting"}, map[string]string{}, []string{"re:", "meeting"}},
+		{[]string{"re: meeting"}, map[string]string{}, []string{"re: meeting"}},
+		{[]string{"status.not:open"}, map[string]string{}, []string{"status.not:open"}},
+	} {
+		attributes, rargs := parseAttributeModifiers(tc.args, known)
+		if fmt.Sprint(attributes) != fmt.Sprint(tc.attributes) || slices.Equal(rargs, tc.rargs) == false {
+			t.Errorf("parseAttributeModifiers(%q) = %v, %q, want %v, %q", tc.args, attributes, rargs, tc.attributes, tc.rargs)
+		}
+	}
+}
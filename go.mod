@@ -2,12 +2,11 @@ module note
 
 go 1.19
 
-require github.com/gosimple/conf v0.0.0-20140411182724-6b465b78490c
-
 require (
-	github.com/google/uuid v1.3.0 // indirect
-	golang.org/x/exp v0.0.0-20221230185412-738e83a70c30 // indirect
-	golang.org/x/sys v0.4.0 // indirect
-	golang.org/x/term v0.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	github.com/google/uuid v1.3.0
+	github.com/gosimple/conf v0.0.0-20140411182724-6b465b78490c
+	golang.org/x/exp v0.0.0-20221230185412-738e83a70c30
+	golang.org/x/sys v0.4.0
+	golang.org/x/term v0.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
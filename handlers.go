@@ -11,10 +11,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/exp/slices"
 )
 
 // Command Handler: note ID file add FILE [..]
@@ -25,44 +26,16 @@ func noteFileAddHandler(note Note, args []string) (err error) {
 		return
 	}
 
-MAIN:
 	for _, file := range args[0:] {
-		basename := filepath.Base(file)
-		sha1, err := fileSha1(file)
+		err = note.Attach(file, filepath.Base(file))
 		if err != nil {
-			sha1 = "failed"
-			fmt.Println(err)
-		}
-		for _, a := range note.Attachments {
-			if sha1 == a.Sha1 {
-				fmt.Printf("File with same checksum already attached: %s.\n", a.Filename)
-				continue MAIN
-			}
-		}
-
-		dstDir := filepath.Clean(notemanager.NoteDir + "/" + note.Id.String() + "/" + "attachments")
-		err = os.MkdirAll(dstDir, os.FileMode(notemanager.DirPermission))
-		if err != nil {
-			log.Fatal(err)
-		}
-		err = copyRegularFile(file, filepath.Clean(dstDir+"/"+basename))
-		if err != nil {
-			if err.Error() == "File already exists. Aborting." {
-				fmt.Printf("File already attached. Use another name: %s.\n", basename)
-			}
 			fmt.Println(err)
 			continue
 		}
-		note.Attachments = append(note.Attachments, Attachment{
-			Filename:    basename,
-			Sha1:        sha1,
-			DateCreated: time.Now().UTC(),
-		})
-		note.WriteData()
 		fmt.Printf("%s: Attached file %s.\n", note.ShortId(), file)
 	}
 
-	return
+	return nil
 }
 
 func noteFileHandler(note Note, args []string) (err error) {
@@ -125,11 +98,13 @@ func modifyHandler(filter NoteFilter, notes []Note, args []string) (err error) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	known := attributeKeys(notes)
+	_, rargs = parseAttributeModifiers(rargs, known)
 	if len(notes) > 1 && len(rargs) > 0 {
 		Exit("Cannot rename multiple notes")
 	}
 	for _, n := range notes {
-		err = noteModifyHandler(n, args)
+		err = noteModifyHandler(n, args, known)
 		if err != nil {
 			Exit(err.Error())
 		}
@@ -137,8 +112,9 @@ func modifyHandler(filter NoteFilter, notes []Note, args []string) (err error) {
 	return
 }
 
-// Modify tag or title of single note.
-func noteModifyHandler(n Note, args []string) (err error) {
+// Modify tag or title of single note. known are the attribute keys,
+// which can be removed, see parseAttributeModifiers.
+func noteModifyHandler(n Note, args []string, known []string) (err error) {
 	if len(args) == 0 {
 		Exit("Not enough parameters")
 		return
@@ -154,6 +130,20 @@ func noteModifyHandler(n Note, args []string) (err error) {
 		return
 	}
 	n.RemoveTags(delTags)
+
+	attributes, rargs := parseAttributeModifiers(rargs, known)
+	for k, v := range attributes {
+		if slices.Contains(dateAttributes, k) {
+			err = n.SetDate(k, v)
+		} else {
+			err = n.SetAttribute(k, v)
+		}
+		if err != nil {
+			Exit(err.Error())
+			return
+		}
+	}
+
 	if len(rargs) > 0 {
 		n.Title = strings.Join(rargs, " ")
 	}
@@ -166,88 +156,208 @@ func noteModifyHandler(n Note, args []string) (err error) {
 }
 
 // CMD: note UUID edit
-func noteEditHandler(n Note) (err error) {
-	in, err := os.ReadFile(n.Path() + `/` + n.LatestVersion())
+func noteEditHandler(n Note, line int) (err error) {
+	latest, err := os.ReadFile(n.Path() + `/` + n.LatestVersion())
 	if err != nil {
 		log.Fatal(err)
 	}
+	in := latest
+	baseVersion := n.LatestVersion()
 
 	// Create a temporary file in Notemanager tmp dir.
-	// Once the note editor has been closed check if checksum
-	// differs from the temporary file. If yes, move the file into
+	// Once the note editor has been closed check if content
+	// differs from the latest version. If yes, move the file into
 	// note directory and create data file.
-	tmpFile := filepath.Clean(fmt.Sprintf("%s/tmp/%s", notemanager.DataDir, n.Id.String()))
-	err = os.WriteFile(tmpFile, in, 0600)
-	defer os.Remove(tmpFile)
+	// The file is kept if anything fails, so it can be recovered
+	// with note drafts.
+	tmpFile := n.tmpFile()
+
+	// continue with an unsaved draft of a previous edit
+	for _, d := range noteDrafts(n.Id) {
+		if askYesNo(fmt.Sprintf("%s: Found unsaved draft from %s. Continue editing the draft?", n.ShortId(), d.ModTime.Local().Format(notemanager.OutputTimeFormatLong))) {
+			in, err = os.ReadFile(d.Path())
+			if err != nil {
+				return
+			}
+			baseVersion = d.BaseVersion(n)
+			err = os.Rename(d.Path(), tmpFile)
+			if err != nil {
+				return
+			}
+			break
+		}
+	}
+
+	err = os.WriteFile(tmpFile, in, notemanager.FilePermission)
 	if err != nil {
 		return
 	}
 
-	chksumBefore, err := fileSha1(tmpFile)
+	err = runEditor(tmpFile, line)
 	if err != nil {
-		log.Fatal(err)
+		return
 	}
 
-	// Run the Editor to edit tmpFile
-	cmd := exec.Command(notemanager.Editor, tmpFile)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
+	// Editor Done
+	out, err := os.ReadFile(tmpFile)
+	if err != nil {
+		return
+	}
 
-	err = cmd.Run()
+	if bytes.Equal(latest, out) {
+		os.Remove(tmpFile)
+		return
+	}
+
+	err = commitNoteEdit(n, baseVersion)
+	return
+}
+
+// Create a new note version from the temporary file of note n.
+// baseVersion is the version the edit started with. If other versions
+// have been created since, the user is asked how to resolve the
+// concurrent edit.
+func commitNoteEdit(n Note, baseVersion string) (err error) {
+	unlock, err := lockDataDir()
 	if err != nil {
 		return
 	}
+	defer unlock()
 
-	// Editor Done
-	fileinfo, err := os.Stat(tmpFile)
+	// Reload note, as another process could have created
+	// a version while the editor was open.
+	current, err := loadNote(n.Id.String())
 	if err != nil {
 		return
 	}
-	//timestampAfter := fileinfo.ModTime()
-	version := fileinfo.ModTime().UTC().Format(notemanager.VersionTimeFormat)
-	chksumAfter, err := fileSha1(tmpFile)
+
+	if current.LatestVersion() != baseVersion {
+		var base []byte
+		base, err = current.Content(baseVersion)
+		if err != nil {
+			return
+		}
+		err = resolveConcurrentEdit(n, current, base, n.tmpFile())
+		if err != nil {
+			return
+		}
+	}
+	n = current
+
+	fileinfo, err := os.Stat(n.tmpFile())
 	if err != nil {
-		log.Fatal(err)
+		return
 	}
+	version := n.newVersion(fileinfo.ModTime())
 	n.Versions = append(n.Versions, version)
 
-	if chksumBefore != chksumAfter {
-		err = n.moveTmpFile()
+	err = n.moveTmpFile()
+	if err != nil {
+		return
+	}
+	n.DateModified = append(n.DateModified, time.Now().UTC())
+	// the new version resolves conflicts of sync
+	n.Conflicts = nil
+	err = n.WriteData()
+	if err != nil {
+		return
+	}
+	fmt.Println(n.ShortId() + ": Created note version " + version)
+
+	return
+}
+
+// Another version of the note has been created while the note was edited
+// in tmpFile. Ask the user to merge both versions or keep both, in which
+// case the edited version becomes the latest version. base is the content
+// the edit started with. Without a terminal to ask, both versions are
+// merged, unless they conflict.
+func resolveConcurrentEdit(n Note, current Note, base []byte, tmpFile string) (err error) {
+	fmt.Printf("%s: Note was modified while editing, latest version is now %s.\n", n.ShortId(), current.LatestVersion())
+
+	if stdinIsTerminal() == false {
+		merged, conflicts, err := mergeConcurrentEdit(current, base, tmpFile)
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		if conflicts > 0 {
+			return fmt.Errorf("%d conflict(s) with version %s, edit aborted. Your changes are kept in %s", conflicts, current.LatestVersion(), tmpFile)
+		}
+		return os.WriteFile(tmpFile, merged, notemanager.FilePermission)
+	}
+
+	fmt.Printf("[m]erge versions, [k]eep both, [a]bort: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	input, _ := reader.ReadString('\n')
+	input = strings.TrimSpace(strings.ToLower(input))
+
+	switch input {
+	case "m", "merge":
+		var merged []byte
+		var conflicts int
+		merged, conflicts, err = mergeConcurrentEdit(current, base, tmpFile)
+		if err != nil {
+			return
+		}
+		err = os.WriteFile(tmpFile, merged, notemanager.FilePermission)
+		if err != nil {
+			return
+		}
+
+		if conflicts > 0 {
+			fmt.Printf("%s: %d conflict(s), resolve them in the editor.\n", n.ShortId(), conflicts)
+			err = runEditor(tmpFile, 0)
 		}
-		n.DateModified = append(n.DateModified, time.Now().UTC())
-		n.WriteData()
-		fmt.Println(n.ShortId() + ": Created note version " + version)
+
+	case "k", "keep":
+		// nothing to do, the other version stays in the history
+
+	default:
+		err = errors.New("Edit aborted. Your changes are kept in " + tmpFile)
 	}
 
 	return
 }
 
-func notePrintHandler(n Note, args []string) (err error) {
-	version := n.LatestVersion()
-	if len(args) > 1 {
-		err = errors.New("Too many arguments")
+// Merge the edit in tmpFile with the latest version of note current.
+// Returns the merged content and the number of conflicts.
+func mergeConcurrentEdit(current Note, base []byte, tmpFile string) (merged []byte, conflicts int, err error) {
+	theirs, err := current.Content()
+	if err != nil {
+		return
 	}
-	if len(args) > 0 {
-		version = args[0]
+	mine, err := os.ReadFile(tmpFile)
+	if err != nil {
+		return
 	}
-	fmt.Printf("%s", n.Output(version))
-	return
+
+	lines, conflicts := merge3(splitLines(base), splitLines(mine), splitLines(theirs), current.LatestVersion())
+	return joinLines(lines), conflicts, nil
 }
 
-func noteReadHandler(n Note, args []string) (err error) {
-	version := n.LatestVersion()
-	if len(args) > 1 {
-		err = errors.New("Too many arguments")
+func notePrintHandler(n Note, o OutputOptions) (err error) {
+	out, err := n.Output(o)
+	if err != nil {
+		return
 	}
-	if len(args) > 0 {
-		version = args[0]
+	fmt.Printf("%s", out)
+	return
+}
+
+func noteReadHandler(n Note, o OutputOptions) (err error) {
+	out, err := n.Output(o)
+	if err != nil {
+		return
 	}
 
 	cmd := exec.Command(notemanager.TerminalReader)
-	cmd.Stdin = bytes.NewReader(n.Output(version))
+	cmd.Stdin = bytes.NewReader(out)
 	cmd.Stdout = os.Stdout
+	// let less display colors of rendered Markdown
+	if o.Render && os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=R")
+	}
 
 	err = cmd.Run()
 	if err != nil {
@@ -270,157 +380,137 @@ func noteVersionsHandler(n Note, args []string) (err error) {
 	return
 }
 
-// display collection of tags
-func tagsHandler(filter NoteFilter, args []string) (err error) {
+func searchHandler(filter NoteFilter, args []string) (err error) {
 	var optHelp bool
-	var optOrder string
-	var optFull bool
-	fs := flag.NewFlagSet("note tags", flag.ContinueOnError)
-	fs.Usage = func() { helpNoteTags() }
+	var optCaseSensitive bool
+	var optEdit bool
+	fs := flag.NewFlagSet("notemanager search", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteSearch() }
 	fs.BoolVar(&optHelp, "h", false, "Display usage")
 	fs.BoolVar(&optHelp, "help", false, "Display usage")
-	fs.BoolVar(&optFull, "f", false, "Display notes along with tags")
-	fs.BoolVar(&optFull, "full", false, "Display notes along with tags")
-	fs.StringVar(&optOrder, "o", "count", "Ordering of tags. OPTIONS=count|name")
-	fs.StringVar(&optOrder, "order", "count", "Ordering of tags. OPTIONS=count|name")
+	fs.BoolVar(&optCaseSensitive, "s", false, "Perform case sensitive search")
+	fs.BoolVar(&optCaseSensitive, "case-sensitive", false, "Perform case sensitive search")
+	fs.BoolVar(&optEdit, "e", false, "Edit matching notes at the first matching line")
+	fs.BoolVar(&optEdit, "edit", false, "Edit matching notes at the first matching line")
 	if err = fs.Parse(args); err != nil {
 		return
 	}
 
-	if optHelp {
-		helpNoteTags()
+	if optHelp || len(args) == 0 {
+		helpNoteSearch()
 	}
 
-	args = fs.Args()
-	/* filter, rargs, err := parseFilter(args)
-	fmt.Sprintln(rargs) */
+	rargs := fs.Args()
 	notes, err := notes(filter)
-	if err != nil {
-		log.Fatal(err)
-	}
 
-	type TaggedNote struct {
-		Id          string
-		Title       string
-		DateCreated time.Time
-	}
-	tags := make(map[string][]TaggedNote)
-	for _, n := range notes {
-		for _, tag := range n.Tags {
-			tags[tag] = append(tags[tag], TaggedNote{n.ShortId(), n.Title, n.DateCreated})
-		}
+	if len(rargs) > 1 {
+		Exit("Too many arguments")
 	}
+	needle := rargs[0]
 
-	// generate slice of tags
-	keys := make([]string, 0, len(tags))
-	for key := range tags {
-		keys = append(keys, key)
+	matches, err := searchNotes(notes, needle, optCaseSensitive)
+	if err != nil {
+		Exit(err.Error())
 	}
 
-	// sort alphabetically by tag first
-	// or results of multiple calls vary slightly.
-	sort.Strings(keys)
+	fmt.Println("Search Results for pattern: " + needle)
+	fmt.Println("Matches found:", len(matches))
 
-	switch optOrder {
-	case "count":
-		sort.SliceStable(keys, func(i, j int) bool {
-			return len(tags[keys[i]]) > len(tags[keys[j]])
-		})
+	if len(matches) > 0 {
+		fmt.Println()
+		fmt.Println(`Matches:`)
+
+		for _, m := range matches {
+			lines := make([]string, 0, len(m.Lines))
+			for _, l := range m.Lines {
+				lines = append(lines, strconv.Itoa(l))
+			}
+			fmt.Printf(" - %s at lines %s\n", m.Note.ShortId(), strings.Join(lines, ", "))
+		}
 	}
 
-	// Default Output
-	for _, k := range keys {
-		fmt.Printf("%s (%d)\n", k, len(tags[k]))
-		if optFull {
-			for _, t := range tags[k] {
-				fmt.Printf("  - %s: %s (%s)\n", t.Id, t.Title, t.DateCreated.Format(notemanager.OutputTimeFormatShort))
+	if optEdit {
+		for _, m := range matches {
+			err = noteEditHandler(m.Note, m.Lines[0])
+			if err != nil {
+				Exit(err.Error())
 			}
-			fmt.Println()
 		}
 	}
 
 	return
 }
 
-func searchHandler(filter NoteFilter, args []string) (err error) {
-	var optHelp bool
-	var optCaseSensitive bool
-	fs := flag.NewFlagSet("notemanager search", flag.ContinueOnError)
-	fs.Usage = func() { helpNoteSearch() }
-	fs.BoolVar(&optHelp, "h", false, "Display usage")
-	fs.BoolVar(&optHelp, "help", false, "Display usage")
-	fs.BoolVar(&optCaseSensitive, "s", false, "Perform case sensitive search")
-	fs.BoolVar(&optCaseSensitive, "case-sensitive", false, "Perform case sensitive search")
-	if err = fs.Parse(args); err != nil {
-		return
-	}
+// note of which the latest version matches a search pattern
+type SearchMatch struct {
+	Note Note
+	// numbers of the matching lines, starting at 1
+	Lines []int
+}
 
-	if optHelp || len(args) == 0 {
-		helpNoteSearch()
+// Returns the notes of which the latest version matches the regular
+// expression needle. The search is case insensitive by default.
+func searchNotes(notes []Note, needle string, caseSensitive bool) (matches []SearchMatch, err error) {
+	matchString := `(?im)(.*%s.*)`
+	if caseSensitive {
+		matchString = `(?m)(.*%s.*)`
 	}
 
-	rargs := fs.Args()
-	notes, err := notes(filter)
-
-	if len(rargs) > 1 {
-		Exit("Too many arguments")
+	r, err := regexp.Compile(fmt.Sprintf(matchString, needle))
+	if err != nil {
+		return
 	}
-	needle := rargs[0]
 
-	type FileMatch struct {
-		Id      string
-		Lines   []string
-		Excerpt string
-	}
-	var matches []FileMatch
 	for _, n := range notes {
-		// search case insensitive by default
-		//matchString := `(?im)(^.*%s.*$)`
-		matchString := `(?im)(.*%s.*)`
-		if optCaseSensitive {
-			matchString = `(?m)(.*%s.*)`
+		if r.Match(n.latestContent) == false {
+			continue
 		}
 
-		r := regexp.MustCompile(fmt.Sprintf(matchString, needle))
-		if r.Match(n.latestContent) {
-			var lines []string
-			sc := bufio.NewScanner(strings.NewReader(string(n.latestContent)))
-			for sc.Scan() {
-				lines = append(lines, sc.Text())
+		var lines []int
+		sc := bufio.NewScanner(strings.NewReader(string(n.latestContent)))
+		for i := 1; sc.Scan(); i++ {
+			if r.MatchString(sc.Text()) {
+				lines = append(lines, i)
 			}
+		}
 
-			var matchLines []string
-			for i, line := range lines {
-				if r.MatchString(line) {
-					matchLines = append(matchLines, strconv.Itoa(i+1))
-				}
-			}
+		if len(lines) > 0 {
+			matches = append(matches, SearchMatch{Note: n, Lines: lines})
+		}
+	}
 
-			if len(matchLines) > 0 {
-				matches = append(matches, FileMatch{
-					Id:      n.ShortId(),
-					Lines:   matchLines,
-					Excerpt: "",
-				})
-			}
+	return
+}
+
+func archiveHandler(filter NoteFilter, notes []Note, args []string) (err error) {
+	if err = requireSelection(filter, notes); err != nil {
+		Exit(err.Error())
+	}
 
-			/* 		matches := r.FindSubmatch(n.latestContent)
-			for _, v := range matches {
-				//	fmt.Printf("Match: %s\n", v)
-			} */
+	for _, n := range notes {
+		err = n.Archive()
+		if err != nil {
+			return
 		}
+
+		fmt.Printf("%s: Archived\n", n.ShortId())
 	}
-	fmt.Println("Search Results for pattern: " + needle)
-	fmt.Println("Matches found:", len(matches))
 
-	if len(matches) > 0 {
-		fmt.Println()
-		fmt.Println(`Matches:`)
+	return
+}
 
-		for _, m := range matches {
-			//fmt.Printf(" - %s\n", m)
-			fmt.Printf(" - %s at lines %s\n", m.Id, strings.Join(m.Lines, ", "))
+func unarchiveHandler(filter NoteFilter, notes []Note, args []string) (err error) {
+	if err = requireSelection(filter, notes); err != nil {
+		Exit(err.Error())
+	}
+
+	for _, n := range notes {
+		err = n.Unarchive()
+		if err != nil {
+			return
 		}
+
+		fmt.Printf("%s: Unarchived\n", n.ShortId())
 	}
 
 	return
@@ -452,20 +542,121 @@ func deleteHandler(notes []Note, args []string) (err error) {
 	return
 }
 
-func printHandler(notes []Note, args []string) (err error) {
+// CMD: note FILTER pin [-p PRIORITY]
+func pinHandler(filter NoteFilter, notes []Note, args []string) (err error) {
+	var optHelp bool
+	var optPriority int
+	fs := flag.NewFlagSet("note pin", flag.ContinueOnError)
+	fs.Usage = func() { helpNotePin() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.IntVar(&optPriority, "p", 0, "Priority of the pinned note")
+	fs.IntVar(&optPriority, "priority", 0, "Priority of the pinned note")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() > 0 {
+		helpNotePin()
+	}
+
+	if err = requireSelection(filter, notes); err != nil {
+		Exit(err.Error())
+	}
+
 	for _, n := range notes {
-		notePrintHandler(n, args)
+		err = n.Pin(optPriority)
+		if err != nil {
+			return
+		}
+
+		fmt.Printf("%s: Pinned\n", n.ShortId())
+	}
+
+	return
+}
+
+func unpinHandler(filter NoteFilter, notes []Note, args []string) (err error) {
+	if err = requireSelection(filter, notes); err != nil {
+		Exit(err.Error())
+	}
+
+	for _, n := range notes {
+		err = n.Unpin()
+		if err != nil {
+			return
+		}
+
+		fmt.Printf("%s: Unpinned\n", n.ShortId())
+	}
+
+	return
+}
+
+// CMD: note [FILTER] pinned [OPTIONS]
+// same as list, restricted to pinned notes
+func pinnedHandler(filter NoteFilter, args []string) {
+	filter.TagsInclude = append(filter.TagsInclude, "PINNED")
+	listHandler(filter, args)
+}
+
+// CMD: note [FILTER] print [OPTIONS] [VERSION]
+// Multiple notes are separated by a line, so the output can be piped.
+func printHandler(notes []Note, args []string) (err error) {
+	o, separator := parseOutputArgs("note print", args)
+	for i, n := range notes {
+		if i > 0 {
+			fmt.Println(separator)
+		}
+		err = notePrintHandler(n, o)
+		if err != nil {
+			Exit(err.Error())
+		}
 	}
 
 	return
 }
 
 func readHandler(notes []Note, args []string) (err error) {
+	o, _ := parseOutputArgs("note read", args)
 	for _, n := range notes {
-		//version := n.LatestVersion()
-		noteReadHandler(n, args)
+		err = noteReadHandler(n, o)
+		if err != nil {
+			Exit(err.Error())
+		}
+	}
+
+	return
+}
+
+// parse arguments of read and print: [OPTIONS] [VERSION]. Markdown is
+// rendered unless disabled in noterc, by --raw or stdout is no terminal.
+func parseOutputArgs(name string, args []string) (o OutputOptions, separator string) {
+	var optHelp bool
+	var optRaw bool
+	var optNoHeader bool
+	var optHeaderOnly bool
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() { helpNotePrint() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.BoolVar(&optRaw, "raw", false, "Display content without rendering Markdown")
+	fs.BoolVar(&optNoHeader, "no-header", false, "Display content only")
+	fs.BoolVar(&optHeaderOnly, "header-only", false, "Display header only")
+	fs.StringVar(&separator, "s", "", "Line between multiple notes")
+	fs.StringVar(&separator, "separator", "", "Line between multiple notes")
+	if err := fs.Parse(args); err != nil {
+		Exit(err.Error())
+	}
+
+	if optHelp || fs.NArg() > 1 || (optNoHeader && optHeaderOnly) {
+		helpNotePrint()
 	}
 
+	o.Version = fs.Arg(0)
+	o.Header = optNoHeader == false
+	o.Content = optHeaderOnly == false
+	o.Render = optRaw == false && notemanager.RenderMarkdown && stdoutIsTerminal()
 	return
 }
 
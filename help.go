@@ -1,6 +1,9 @@
 package main
 
-import "log"
+import (
+	"fmt"
+	"log"
+)
 
 func helpNote() {
 	x := `USAGE
@@ -12,26 +15,68 @@ func helpNote() {
 COMMANDS
     ./note add [TAG...] TITLE
         Create a note
+    ./note journal [today|yesterday|DATE|week|list [--month]]
+        Open or list daily journal notes
     ./note [FILTER] [read]
         Read note with pagination
     ./note [FILTER] edit [VERSION]
         Create a new note version based on VERSION
     ./note [FILTER] list [notes|templates]
         List notes
-    ./note [FILTER] tags [OPTIONS]
-        List note tags
+    ./note [FILTER] pinned [OPTIONS]
+        List pinned notes
+    ./note [FILTER] tags [OPTIONS] [rename|merge|delete]
+        List, rename, merge or delete note tags
+    ./note template [list|add|edit|show|delete|rename|render] [NAME]
+        Manage note templates
     ./note [FILTER] search [OPTIONS] [REGEXP]
         Search for regular expression matches
     ./note [FILTER] delete
         Mark note as deleted
+    ./note [FILTER] archive|unarchive
+        Archive finished notes, archived notes are hidden. Notes must be selected by ID or alias, unless the FILTER matches exactly one note.
+    ./note [FILTER] pin [-p PRIORITY]|unpin
+        Pin note to the top of note lists
     ./note [FILTER] print
         Print note content
     ./note [FILTER] versions
         Print the note versions
     ./note [FILTER] file { add | browse | list }
         Manage note file attachments
-    ./note [FILTER] modify [TAGMODIFIER...] [TITLE]
-        Modify note tags and title
+    ./note [FILTER] modify [TAGMODIFIER...] [KEY:VALUE...] [TITLE]
+        Modify note tags, attributes and title. The keys due and review
+        set dates, e.g. due:2024-05-01. KEY: removes an attribute, which
+        is set, otherwise it is part of the title.
+    ./note [FILTER] agenda [OPTIONS]
+        List overdue and upcoming notes
+    ./note [FILTER] todos [OPTIONS]
+        List checkbox items of notes
+    ./note ID todo done|open LINE
+        Check or uncheck a checkbox item
+    ./note [FILTER] append|prepend [OPTIONS] [TEXT|-]
+        Add text to the end or beginning of a note
+    ./note backup FILE
+        Create a backup archive of the data directory
+    ./note restore-archive [OPTIONS] FILE
+        Verify and restore a backup archive
+    ./note drafts [list|recover DRAFT|discard DRAFT]
+        Manage unsaved drafts of notes
+    ./note fsck [OPTIONS]
+        Check integrity of the data directory
+    ./note config [show|get KEY|set KEY VALUE]
+        Display or change settings of the noterc
+    ./note migrate-datadir NEW
+        Move the data directory to NEW
+    ./note notebook [list|use NAME]
+        List notebooks or select the notebook in use
+    ./note [FILTER] move|copy --to NOTEBOOK
+        Move or copy notes to another notebook
+    ./note sync DIR
+        Merge notes with a shared directory in both directions
+    ./note serve [--listen ADDR]
+        Serve notes by an HTTP/JSON API
+    ./note web [--listen ADDR]
+        Browse notes in a read-only web interface
     ./note version
         Display Notemanager version
 
@@ -40,9 +85,394 @@ COMMANDS
 	log.Fatal(Autobreak(x))
 }
 
+func helpNoteAgenda() {
+	x := `USAGE
+    ./note [FILTER] agenda [OPTIONS]
+
+
+DESCRIPTION
+    List notes of the selection matching the FILTER terms, which are
+    overdue, due within the next days or due for review. Dates are set
+    with modify, e.g.:
+    ./note ID modify due:2024-05-01 review:"2024-06-01 09:00"
+    An empty value removes the date: ./note ID modify due:
+
+    Due dates without a time are due until the end of the day. Notes
+    have the virtual tags OVERDUE, DUE (due within 7 days) and REVIEW
+    (review date reached), e.g.: ./note +OVERDUE list
+
+
+ARGUMENTS
+    FILTER
+        For explanation of filters run: ./note -h
+    OPTIONS
+        -d|--days NUMBER
+            Number of days to look ahead. [Default: 7]
+        -h|--help
+            Display usage
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteTodos() {
+	x := `USAGE
+    ./note [FILTER] todos [OPTIONS]
+    ./note ID todo done|open LINE
+
+
+DESCRIPTION
+    List the Markdown checkbox items, e.g. "- [ ] item", of the latest
+    versions of a selection of notes matching the FILTER terms, along
+    with their line numbers. Notes with unchecked items have the virtual
+    tag OPENTODO.
+
+    todo done checks the item at LINE of a note, todo open unchecks it.
+    A new version of the note is created.
+
+
+ARGUMENTS
+    FILTER
+        For explanation of filters run: ./note -h
+    OPTIONS
+        -h|--help
+            Display usage
+        -o|--open
+            Display unchecked items only
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNotePrint() {
+	x := `USAGE
+    ./note [FILTER] read [OPTIONS] [VERSION]
+    ./note [FILTER] print [OPTIONS] [VERSION]
+
+
+DESCRIPTION
+    Display the latest version or VERSION of a selection of notes
+    matching the FILTER terms. read displays the notes with the
+    TerminalReader (Default: less), print writes them to stdout.
+
+    Markdown is rendered with colors and wrapped to the width of the
+    terminal. It is disabled if stdout is not a terminal, by --raw or
+    in noterc with: markdown = false
+
+    print writes multiple notes separated by a line, which is empty by
+    default. Every note ends with a newline.
+
+
+HEADER
+    The header is rendered from the template 'output' in the template
+    directory, which can be changed in noterc with: outputTemplate = NAME
+    If the template does not exist, a built-in layout is used. Run
+    './note template add output' to start with a copy of it.
+
+    Besides the data of note templates, .Version and .Modified are
+    available. {{ date TIME }} formats a time in the long output format,
+    {{ join LIST SEP }} joins tags, e.g. {{ join .Note.VirtualTags ", " }}
+
+
+ARGUMENTS
+    FILTER
+        For explanation of filters run: ./note -h
+    OPTIONS
+        -h|--help
+            Display usage
+        --header-only
+            Display header only
+        --no-header
+            Display content only
+        --raw
+            Display content as it is stored
+        -s|--separator LINE
+            Line between multiple notes of print, e.g. to split the output
+            into files. [Default: empty line]
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteConfig() {
+	x := `USAGE
+    ./note config [show|get KEY|set KEY VALUE]
+
+
+DESCRIPTION
+    Display or change settings of the noterc. show displays all settings
+    in effect, get a single setting. set validates VALUE and writes it
+    to the noterc, other lines of the file are kept. Keys are case
+    insensitive.
+
+    The noterc is read from the path given by the --rc option, the
+    environment variable NOTERC or the default path. The environment
+    variables NOTE_DATADIR and NOTE_EDITOR override the settings of the
+    noterc.
+
+    The editor is a command line with arguments, e.g.
+    editor = code --wait
+    {file} and {line} are replaced by the file and the line to edit,
+    otherwise the file is appended. Without an editor setting, $VISUAL
+    or $EDITOR is used.
+
+    On unix the default paths follow the XDG base directories, i.e.
+    $XDG_CONFIG_HOME/notemanager/noterc and $XDG_DATA_HOME/notemanager.
+    If only the legacy paths ~/.noterc and ~/.notes exist, they are used
+    instead. Move the data directory with: ./note migrate-datadir NEW
+
+
+KEYS
+`
+	for _, o := range configOptions {
+		x += fmt.Sprintf("    %s\n        %s\n", o.Key, o.Description)
+	}
+	x += "\n"
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteMigrateDataDir() {
+	x := `USAGE
+    ./note migrate-datadir NEW
+
+
+DESCRIPTION
+    Move the data directory to the empty or missing directory NEW. All
+    files are copied and verified by their sha1 checksums. Then the
+    datadir of the noterc is set to NEW and the old data directory is
+    removed. If the copy cannot be verified, the old data directory is
+    kept. For a notebook other than the default one, the datadir of the
+    notebook is set.
+
+    The migration is refused if the data directory contains other than
+    regular files, e.g. symlinks, or if it is set by NOTE_DATADIR.
+
+
+ARGUMENTS
+    OPTIONS
+        -h|--help
+            Display usage
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteNotebook() {
+	x := `USAGE
+    ./note notebook [list|use NAME]
+
+
+DESCRIPTION
+    Notebooks are named data directories, which are set in the noterc,
+    e.g.:
+    notebook.work.datadir = ~/work/notes
+    The data directory of the datadir setting is the notebook default.
+
+    list displays all notebooks, the notebook in use is marked with *.
+    use NAME writes the notebook setting to the noterc, so NAME is used
+    by default. A single command uses another notebook with the option
+    -n|--notebook NAME, e.g.: ./note -n work list
+    The option is preferred over NOTE_DATADIR, which is preferred over
+    the notebook setting.
+
+
+ARGUMENTS
+    OPTIONS
+        -h|--help
+            Display usage
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteTransfer() {
+	x := `USAGE
+    ./note FILTER move|copy --to NOTEBOOK
+
+
+DESCRIPTION
+    Move or copy a selection of notes matching the FILTER terms to
+    another notebook with all versions, attachments and aliases. The
+    copies are verified by their sha1 checksums before move removes the
+    notes from the current notebook. Aliases which already exist in the
+    target notebook are not transferred.
+
+    Notes must be selected by ID or alias, unless the FILTER terms match
+    exactly one note.
+
+
+ARGUMENTS
+    FILTER
+        For explanation of filters run: ./note -h
+    OPTIONS
+        --to NOTEBOOK
+            Target notebook
+        -h|--help
+            Display usage
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteSync() {
+	x := `USAGE
+    ./note sync DIR
+
+
+DESCRIPTION
+    Merge the notes and aliases of the data directory with the shared
+    directory DIR, e.g. a network folder, in both directions. DIR has the
+    layout of a data directory and is created if it is missing.
+
+    Notes missing on either side are copied. Versions and attachments of
+    a note are merged, its title, tags and attributes are taken from the
+    side which changed last. If both sides have new versions of a note,
+    all versions are kept and the note has the virtual tag CONFLICT:
+    ./note +CONFLICT list
+    Compare the versions with ./note ID versions and resolve the conflict
+    by editing the note. Attachments with the same name and different
+    content are both kept, one is renamed by its checksum.
+
+    Aliases missing on either side are added, unless the note has
+    another alias on that side. Aliases which refer to different notes
+    are reported and not merged.
+
+
+ARGUMENTS
+    OPTIONS
+        -h|--help
+            Display usage
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteServe() {
+	x := `USAGE
+    ./note serve [--listen ADDR]
+
+
+DESCRIPTION
+    Serve the notes of the data directory by an HTTP/JSON API. Clients
+    authenticate by the token of the serveToken setting:
+    ./note config set serveToken TOKEN
+    Authorization: Bearer TOKEN
+
+    Notes are selected by id, short id or alias. The filter parameter has
+    the syntax of the command line, e.g. filter=+work created.after:2024-01-01
+    Errors are returned as {"error": "MESSAGE"}. Request bodies are limited to 1 MiB, attachments to 100 MiB.
+
+
+ENDPOINTS
+    GET /notes?filter=TERMS&all=true
+        List notes, all includes deleted and archived notes
+    POST /notes
+        Create note of {"title", "tags", "content", "template"}
+    GET /notes/ID
+        Note metadata
+    PATCH /notes/ID
+        Modify note by {"title", "addTags", "removeTags", "attributes"}
+    DELETE /notes/ID
+        Mark note as deleted
+    POST /notes/ID/undelete
+        Undelete note
+    GET /notes/ID/content?version=VERSION
+        Content of VERSION, by default of the latest version
+    GET /notes/ID/versions
+        List versions
+    POST /notes/ID/append|prepend
+        Add the text of the request body
+    GET /notes/ID/attachments
+        List attachments
+    GET /notes/ID/attachments/NAME
+        Download attachment
+    PUT /notes/ID/attachments/NAME
+        Attach the request body as NAME
+    GET /search?q=REGEXP&filter=TERMS&case=true
+        Search latest versions, case sensitive if case is true
+
+
+ARGUMENTS
+    OPTIONS
+        -l|--listen ADDR
+            Listen address. [Default: 127.0.0.1:8080]
+        -h|--help
+            Display usage
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteWeb() {
+	x := `USAGE
+    ./note web [--listen ADDR]
+
+
+DESCRIPTION
+    Serve a read-only web interface of the notes of the data directory.
+    Notes are listed by tags of the sidebar or by filter terms with the
+    syntax of the command line. Notes are displayed with rendered
+    Markdown, along with their attachments and versions. The changes of
+    every version can be displayed as diff.
+
+    All files of the interface are built into note, so it works offline.
+    If the serveToken setting is set, the browser asks for it as
+    password, the user name is ignored.
+
+
+ARGUMENTS
+    OPTIONS
+        -l|--listen ADDR
+            Listen address. [Default: 127.0.0.1:8081]
+        -h|--help
+            Display usage
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
+func helpNotePin() {
+	x := `USAGE
+    ./note FILTER pin [OPTIONS]
+    ./note FILTER unpin
+
+
+DESCRIPTION
+    Pin or unpin a selection of notes matching the FILTER terms. Pinned
+    notes are listed first, ordered by priority, and have the virtual tag
+    PINNED. List pinned notes only with: ./note pinned
+
+    Notes must be selected by ID or alias, unless the FILTER terms match
+    exactly one note.
+
+
+ARGUMENTS
+    FILTER
+        For explanation of filters run: ./note -h
+    OPTIONS
+        -h|--help
+            Display usage
+        -p|--priority NUMBER
+            Notes with a higher priority are listed first. [Default: 0]
+
+`
+
+	log.Fatal(Autobreak(x))
+}
+
 func helpNoteList() {
 	x := `USAGE
-    ./note [FILTER] list [notes|templates]
+    ./note [FILTER] list [OPTIONS] [notes|templates]
 
 
 DESCRIPTION
@@ -50,6 +480,11 @@ DESCRIPTION
 
 
 ARGUMENTS
+    OPTIONS
+        -a|--all
+            Show all notes, include deleted and archived
+        -c|--columns COLUMN,...
+            Columns of the note table. Available are id, tags, title, alias, created, modified, due, review, pinned and the names of user defined attributes. [Default: id,tags,title,created]
     PARAMETERS
         notes       List notes [Default]
         templates   List templates
@@ -74,6 +509,10 @@ ARGUMENTS
     FILTER
         For explanation of filters run: ./note -h
     OPTIONS
+        -e|--edit
+            Edit the matching notes one after another. The editor opens
+            at the first matching line, if the editor setting has a
+            {line} placeholder, e.g.: editor = vim +{line} {file}
         -s|--case-sensitive
             Perform case sensitive pattern matching
     REGEXP
@@ -87,7 +526,7 @@ ARGUMENTS
 func helpFilter() (x string) {
 	x = `FILTER
     DESCRIPTION
-        FILTER is a collection of options and terms or just a list of specific note ids to built a selection of notes. All supplied filter terms must match in order for a note to be included in the note selection. By default deleted notes are excluded from the note selection.
+        FILTER is a collection of options and terms or just a list of specific note ids to built a selection of notes. All supplied filter terms must match in order for a note to be included in the note selection. By default deleted and archived notes are excluded from the note selection. Archived notes are selected by the +ARCHIVED term.
 
 
     SYNTAX
@@ -97,9 +536,15 @@ func helpFilter() (x string) {
     ARGUMENTS
         OPTIONS
             -a|--all
-                Select all notes, include deleted notes
+                Select all notes, include deleted and archived notes
             -h|--help   
                 Display Notemanager Usage
+            -n|--notebook NAME
+                Use notebook NAME, see ./note notebook -h
+            --rc PATH
+                Read the noterc from PATH
+            --strict
+                Abort if a note cannot be loaded. By default broken notes are skipped and reported.
 
 
         TERMS
@@ -114,9 +559,13 @@ func helpFilter() (x string) {
             modified.before:TIMESTAMP
                 Notes modified before date
             +string
-                Notes with tag string
+                Notes with tag string or one of its sub tags, e.g. +work matches work.clientA
             -string
-                Notes without tag string
+                Notes without tag string and its sub tags
+            key:value
+                Notes with user defined attribute key set to value. If value is empty, notes without the attribute.
+            key.not:value
+                Notes without attribute key set to value. If value is empty, notes with the attribute.
     
     
             TIMESTAMP:
@@ -137,10 +586,20 @@ func helpFilter() (x string) {
 func helpNoteTags() {
 	x := `USAGE
     ./note [FILTER] tags [OPTIONS]
+    ./note [FILTER] tags rename OLD NEW
+    ./note [FILTER] tags merge FROM INTO
+    ./note [FILTER] tags delete TAG
 
 
 DESCRIPTION
     List all note tags of a selection of notes matching the FILTER terms.
+    Tags are hierarchical, levels are separated by dots or slashes, e.g.
+    work.clientA. Tags are displayed as a tree, where the count of a tag
+    includes its sub tags.
+
+    rename, merge and delete change the tags of all notes matching the
+    FILTER terms, including deleted notes. Sub tags are changed as well.
+    rename fails, if NEW already exists; merge combines both tags.
 
 
 ARGUMENTS
@@ -149,6 +608,8 @@ ARGUMENTS
     OPTIONS
         -f|--full
             Display notes along with tags
+        --flat
+            Display full tag names instead of a tree
         -h|--help
             Display usage
         -o|--order count|name
@@ -167,20 +628,61 @@ func helpNoteAdd() {
 DESCRIPTION
     Create a note with supplied TAG and TITLE parameters. When issueing the command an editor will be started where the note content can be written to.
 
+    If the note content is supplied with -m or -f, or piped to stdin, the note is created from the supplied content without starting the editor. Empty input of stdin, e.g. of /dev/null, is ignored. The content replaces the placeholder {{ nm.content }} of the template, or is appended to the template.
+
 
 ARGUMENTS
     OPTIONS
+        -f|--file FILE
+            Read note content from FILE. Use - to read stdin.
+        -m|--message TEXT
+            Use TEXT as note content
         -t|--template
             Use template file as note layout. [Default=note]
     TAG
         Tags are strings prefixed by a '+' sign. Multiple tags can be supplied by separating them by spaces.
     TITLE
-        All remaining arguments after TAG build the note's title. You usually do not need to wrap the title in quotes, unless you want to keep white spaces for some reason. [Default=Undefined]
+        All remaining arguments after TAG build the note's title. You usually do not need to wrap the title in quotes, unless you want to keep white spaces for some reason. A title pattern of the template replaces it. [Default=Undefined]
+
+
+TEMPLATES
+    Templates are files in the templates directory of the data directory. The template 'note' is used by default. Templates with the extension .tmpl, e.g. note.tmpl, use the syntax of Go text/template described below. Files without the extension are plain text, in which only the placeholders {{ nm.* }} are replaced. Rename a file to NAME.tmpl to use the template syntax.
+
+    Data
+        {{ .Note.Title }}, {{ .Note.Id }}, {{ .Note.Tags }}
+            Metadata of the new note
+        {{ .Config.Editor }}
+            Notemanager configuration
+        {{ .Env.HOME }}
+            Environment variables
+        {{ .Content }}
+            Content supplied by -m or -f
+        {{ nm.id }}, {{ nm.title }}, {{ nm.tags }}, {{ nm.created.date }}, {{ nm.created.time }}, {{ nm.created.offset }}, {{ nm.content }}
+            Placeholders of previous versions
+    Functions
+        {{ now | addDays 1 | format "2006-01-02" }}
+            Current time, date arithmetic and formatting
+        {{ env "USER" }}
+            Value of environment variable
+        {{ prompt "Customer" ["default"] }}
+            Ask for a value when the note is created
+        {{ include "NAME" }}
+            Content of another template
+    Front matter
+        A template may start with a YAML block enclosed by --- lines to set defaults of the note. title and alias are templates themselves.
+            ---
+            tags: [meeting]
+            title: 'Meeting {{ prompt "Customer" }}'
+            alias: 'mtg{{ now | format "0102" }}'
+            ---
 
 
 EXAMPLE
     Create a note with tags 'important' and 'exam' with the title 'Exam Deadline'
         note add +important +exam Exam Deadline
+
+    Create a note from the output of a command
+        echo "Deploy done" | note add +log Deploy
 `
 
 	log.Fatal(Autobreak(x))
@@ -232,3 +734,159 @@ ARGUMENTS
 `
 	log.Fatal(Autobreak(x))
 }
+
+func helpNoteBackup() {
+	x := `USAGE
+    ./note backup FILE
+
+
+DESCRIPTION
+    Create a gzip compressed tar archive of the data directory. The archive contains all notes with their versions and attachments, the aliases and the templates. A manifest with the sha1 checksum of every file is stored along with the files. Temporary files are not archived. An existing FILE is never overwritten.
+
+
+EXAMPLE
+    note backup notes-2024-01-31.tar.gz
+`
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteRestoreArchive() {
+	x := `USAGE
+    ./note restore-archive [OPTIONS] FILE
+
+
+DESCRIPTION
+    Verify the checksums of a backup archive created by 'note backup' and restore it into the data directory. By default the data directory must be empty. With --merge the archive is merged into the existing notes. Notes which exist with identical data are skipped. If a note UUID collides with a different local note, the archived note is restored with a new UUID. Aliases and templates which already exist are kept and reported.
+
+
+ARGUMENTS
+    OPTIONS
+        -c|--check
+            Only verify the archive, do not restore it
+        -m|--merge
+            Merge archive into a non-empty data directory
+`
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteFsck() {
+	x := `USAGE
+    ./note drafts [list|recover DRAFT|discard DRAFT]
+        Manage unsaved drafts of notes
+    ./note fsck [OPTIONS]
+
+
+DESCRIPTION
+    Check the integrity of the data directory. Every note must have a parseable data file and every listed version must exist on disk. Version files which are not listed in the data file, attachments with a wrong sha1 checksum, aliases of missing notes, aliases which disagree with the data file of the note and leftover files in the tmp directory are reported. Exits with code 1 if problems remain.
+
+
+ARGUMENTS
+    OPTIONS
+        -q|--quarantine
+            Move notes which cannot be loaded and unknown entries of the note directory into the quarantine directory inside of the data directory.
+        -r|--repair
+            Repair problems which can be fixed without losing data. Orphan versions are added to the note, missing versions are removed, aliases are synchronized with the data files of the notes.
+`
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteDrafts() {
+	x := `USAGE
+    ./note drafts [list|recover DRAFT|discard DRAFT]
+
+
+DESCRIPTION
+    Manage unsaved drafts. If the editor crashes or the terminal is closed while a note is added or edited, the note content is left in the tmp directory. Drafts of a note are also offered for recovery, when the note is edited the next time. Drafts of new notes are offered when the next note is added.
+
+
+ARGUMENTS
+    PARAMETERS
+        list        List drafts [Default]
+        recover DRAFT
+            Create a new version of the note from the draft. If the note does not exist, it is created.
+        discard DRAFT
+            Delete the draft
+    DRAFT
+        Name of the draft as displayed by list, or a unique prefix of it, e.g. the abbreviated note id.
+`
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteAppend() {
+	x := `USAGE
+    ./note [FILTER] append [OPTIONS] [TEXT|-]
+    ./note [FILTER] prepend [OPTIONS] [TEXT|-]
+
+
+DESCRIPTION
+    Create a new version of the selected notes with TEXT added to the end (append) or the beginning (prepend) of the latest version. No editor is started. If TEXT is - or omitted while stdin is not a terminal, the text is read from stdin.
+
+    Notes must be selected by ID or alias, unless the FILTER terms match exactly one note.
+
+
+ARGUMENTS
+    FILTER
+        For explanation of filters run: ./note -h
+    OPTIONS
+        -t|--timestamp
+            Add a header with the current time before the text
+
+
+EXAMPLE
+    Add a line to the running log with alias oncall
+        note oncall append -t restarted db
+`
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteJournal() {
+	x := `USAGE
+    ./note journal [today|yesterday|DATE|week]
+    ./note journal list [OPTIONS]
+
+
+DESCRIPTION
+    Keep a single journal note per day. The journal note of the day is opened in the editor. If it does not exist yet, it is created from the template 'journal' with the tag 'journal'.
+
+
+ARGUMENTS
+    PARAMETERS
+        today       Open journal note of today [Default]
+        yesterday   Open journal note of yesterday
+        DATE        Open journal note of DATE. Syntax: YYYY-MM-DD
+        week        List journal notes of the current week
+        list        List all journal notes
+    OPTIONS
+        -m|--month
+            List journal notes of the current month only
+`
+	log.Fatal(Autobreak(x))
+}
+
+func helpNoteTemplate() {
+	x := `USAGE
+    ./note template [list]
+    ./note template add|edit|show|delete NAME
+    ./note template rename NAME NEWNAME
+    ./note template render NAME [TITLE]
+
+
+DESCRIPTION
+    Manage note templates of the templates directory. Templates are opened in the configured editor and only saved if they are valid. For the template syntax run: ./note add -h
+
+
+ARGUMENTS
+    PARAMETERS
+        list        List templates along with their description [Default]
+        add NAME    Create template and open it in the editor
+        edit NAME   Open template in the editor
+        show NAME   Print template source
+        delete NAME
+            Delete template
+        rename NAME NEWNAME
+            Rename template
+        render NAME [TITLE]
+            Preview template with placeholders filled for a note with TITLE
+`
+	log.Fatal(Autobreak(x))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// date format of the journal attribute of notes
+const journalDateFormat = "2006-01-02"
+
+// CMD: note journal [today|yesterday|DATE|week|list [--month]]
+func journalHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note journal", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteJournal() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp {
+		helpNoteJournal()
+	}
+
+	args = fs.Args()
+	action := "today"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch action {
+	case "today":
+		err = openJournal(today)
+
+	case "yesterday":
+		err = openJournal(today.AddDate(0, 0, -1))
+
+	case "week":
+		// ISO week starts on monday
+		weekday := (int(today.Weekday()) + 6) % 7
+		start := today.AddDate(0, 0, -weekday)
+		err = listJournal(start, start.AddDate(0, 0, 7))
+
+	case "list":
+		err = journalListHandler(today, args[1:])
+
+	default:
+		var date time.Time
+		date, err = parseTimestamp(action)
+		if err != nil {
+			helpNoteJournal()
+		}
+		err = openJournal(date)
+	}
+
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// CMD: note journal list [--month]
+func journalListHandler(today time.Time, args []string) (err error) {
+	var optHelp bool
+	var optMonth bool
+	fs := flag.NewFlagSet("note journal list", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteJournal() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.BoolVar(&optMonth, "m", false, "List journal notes of current month")
+	fs.BoolVar(&optMonth, "month", false, "List journal notes of current month")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() > 0 {
+		helpNoteJournal()
+	}
+
+	if optMonth {
+		start := today.AddDate(0, 0, 1-today.Day())
+		return listJournal(start, start.AddDate(0, 1, 0))
+	}
+
+	return listJournal(time.Time{}, time.Time{})
+}
+
+// Edit the journal note of date. If it does not exist, it is created
+// from the journal template.
+func openJournal(date time.Time) (err error) {
+	n, exists := journalNote(date)
+	if exists {
+		return noteEditHandler(n, 0)
+	}
+
+	day := date.Format(journalDateFormat)
+	n = Note{
+		Id:          uuid.New(),
+		Title:       "Journal " + day,
+		Tags:        []string{"journal"},
+		Journal:     day,
+		DateCreated: time.Now().UTC(),
+	}
+
+	return addNoteFromTemplate(n, "journal", nil, false)
+}
+
+// returns journal note of date. Archived and deleted notes are
+// included, so no second journal note of the date is created.
+func journalNote(date time.Time) (n Note, exists bool) {
+	day := date.Format(journalDateFormat)
+	for _, x := range journalNotes(NoteFilter{IncludeDeleted: true}) {
+		if x.Journal == day {
+			return x, true
+		}
+	}
+	return
+}
+
+// returns journal notes matching filter, sorted by journal date
+func journalNotes(filter NoteFilter) (ret []Note) {
+	all, err := notes(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, n := range all {
+		if n.Journal != "" {
+			ret = append(ret, n)
+		}
+	}
+
+	sort.SliceStable(ret, func(a int, b int) bool {
+		return ret[a].Journal < ret[b].Journal
+	})
+
+	return
+}
+
+// display journal notes with a date in range [from, to).
+// Zero times are not limiting the range.
+func listJournal(from time.Time, to time.Time) (err error) {
+	var count int
+	for _, n := range journalNotes(NoteFilter{}) {
+		day, err := time.ParseInLocation(journalDateFormat, n.Journal, time.Now().Location())
+		if err != nil {
+			continue
+		}
+		if from.IsZero() == false && day.Before(from) {
+			continue
+		}
+		if to.IsZero() == false && day.Before(to) == false {
+			continue
+		}
+
+		fmt.Printf("%s  %s  %s  %s\n", n.Journal, day.Format("Mon"), n.ShortId(), n.Title)
+		count++
+	}
+
+	if count == 0 {
+		fmt.Println("No journal notes found")
+	}
+
+	return
+}
@@ -10,18 +10,19 @@ import (
 	"regexp"
 	"sort"
 	"strings"
-
-	"github.com/google/uuid"
 )
 
 func listHandler(filter NoteFilter, args []string) {
 	var optHelp bool
 	var optAll bool
+	var optColumns string
 	fs := flag.NewFlagSet("note list", flag.ContinueOnError)
 	fs.BoolVar(&optHelp, "h", false, "Display usage")
 	fs.BoolVar(&optHelp, "help", false, "Display usage")
-	fs.BoolVar(&optAll, "a", false, "Show all notes, include deleted")
-	fs.BoolVar(&optAll, "all", false, "Show all notes, include deleted")
+	fs.BoolVar(&optAll, "a", false, "Show all notes, include deleted and archived")
+	fs.BoolVar(&optAll, "all", false, "Show all notes, include deleted and archived")
+	fs.StringVar(&optColumns, "c", "id,tags,title,created", "Comma separated list of columns")
+	fs.StringVar(&optColumns, "columns", "id,tags,title,created", "Comma separated list of columns")
 	if err := fs.Parse(args); err != nil {
 		return
 	}
@@ -44,7 +45,7 @@ func listHandler(filter NoteFilter, args []string) {
 		listTemplates(notemanager.TemplateDir)
 
 	case "notes":
-		listNotes(filter)
+		listNotes(filter, strings.Split(optColumns, ","))
 
 	default:
 		helpNoteList()
@@ -58,62 +59,54 @@ func listTemplates(path string) {
 		log.Fatal(err)
 	}
 	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".") || file.Type().IsRegular() == false {
+			continue
+		}
 		info, err := file.Info()
 		if err != nil {
 			log.Fatal(err)
 		}
-		fmt.Printf("   %s (%d Bytes, modified: %s)\n", file.Name(), info.Size(), info.ModTime())
+
+		name := strings.TrimSuffix(file.Name(), templateExtension)
+		var description string
+		t, err := loadTemplate(name)
+		if err != nil {
+			description = err.Error() + " "
+		} else if t.Description != "" {
+			description = t.Description + " "
+		}
+
+		fmt.Printf("   %s: %s(%d Bytes, modified: %s)\n", name, description, info.Size(), info.ModTime().Local().Format(notemanager.OutputTimeFormatLong))
 	}
 	return
 }
 
-func listNotes(filter NoteFilter) {
-	files, err := os.ReadDir(notemanager.NoteDir)
+// display table of notes matching filter. Fields are the columns of the
+// table, unknown fields are user defined attributes.
+func listNotes(filter NoteFilter, fields []string) {
+	notes, err := notes(filter)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var notes []Note
-	for _, file := range files {
-
-		if file.IsDir() == false {
-			continue
-		}
-
-		noteId := uuid.MustParse(file.Name())
-
-		note, err := loadNote(noteId.String())
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		matches, err := note.MatchesFilter(filter)
-		if err != nil {
-			log.Fatal(err)
-		}
-		if matches {
-			notes = append(notes, note)
-		}
-
-		if string(file.Name()[0]) == "." {
-			continue
-		}
-	}
-
 	// sort notes by DateCreated ASC
 	sort.Slice(notes, func(a int, b int) bool {
 		return notes[a].DateCreated.String() < notes[b].DateCreated.String()
 	})
 
-	fields := []string{
-		"id",
-		"tags",
-		"title",
-		"created",
-	}
+	// pinned notes first, ordered by priority DESC
+	sort.SliceStable(notes, func(a int, b int) bool {
+		if notes[a].Pinned != notes[b].Pinned {
+			return notes[a].Pinned
+		}
+		return notes[a].Priority > notes[b].Priority
+	})
 
 	var output [][]string
 	maxLength := make([]int, len(fields))
+	for j, field := range fields {
+		maxLength[j] = len(field)
+	}
 
 	if len(notes) > 0 {
 		// build 2-dimensional slice of notes.
@@ -136,6 +129,36 @@ func listNotes(filter NoteFilter) {
 
 				case "created":
 					s = n.DateCreated.Local().Format(notemanager.OutputTimeFormatShort)
+
+				case "modified":
+					s = ""
+					if len(n.DateModified) > 0 {
+						s = n.DateModified[len(n.DateModified)-1].Local().Format(notemanager.OutputTimeFormatShort)
+					}
+
+				case "alias":
+					s = n.Alias
+
+				case "due":
+					s = ""
+					if n.DateDue.IsZero() == false {
+						s = n.DateDue.Local().Format(notemanager.OutputTimeFormatShort)
+					}
+
+				case "review":
+					s = ""
+					if n.DateReview.IsZero() == false {
+						s = n.DateReview.Local().Format(notemanager.OutputTimeFormatShort)
+					}
+
+				case "pinned":
+					s = ""
+					if n.Pinned {
+						s = fmt.Sprintf("*%d", n.Priority)
+					}
+
+				default:
+					s = n.Attributes[field]
 				}
 				row = append(row, s)
 
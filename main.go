@@ -7,6 +7,7 @@ import (
 	"os"
 
 	"github.com/gosimple/conf"
+	"golang.org/x/exp/slices"
 )
 
 var cfg *conf.Config
@@ -30,14 +31,21 @@ func main() {
 	var optHelp bool
 	var optAll bool
 	var optVersion bool
+	var optStrict bool
+	var optRc string
+	var optNotebook string
 	fs := flag.NewFlagSet("note", flag.ContinueOnError)
 	fs.Usage = func() { helpNote() }
-	fs.BoolVar(&optAll, "a", false, "Select all notes in filter, include deleted")
-	fs.BoolVar(&optAll, "all", false, "Select all notes in filter, include deleted")
+	fs.BoolVar(&optAll, "a", false, "Select all notes in filter, include deleted and archived")
+	fs.BoolVar(&optAll, "all", false, "Select all notes in filter, include deleted and archived")
 	fs.BoolVar(&optHelp, "h", false, "Display usage")
 	fs.BoolVar(&optHelp, "help", false, "Display usage")
 	fs.BoolVar(&optVersion, "v", false, "Display version")
 	fs.BoolVar(&optVersion, "version", false, "Display version")
+	fs.BoolVar(&optStrict, "strict", false, "Abort if a note cannot be loaded")
+	fs.StringVar(&optRc, "rc", "", "Path of noterc")
+	fs.StringVar(&optNotebook, "n", "", "Use notebook")
+	fs.StringVar(&optNotebook, "notebook", "", "Use notebook")
 	if err := fs.Parse(os.Args[1:]); err != nil {
 		return
 	}
@@ -53,7 +61,27 @@ func main() {
 	// remaining args
 	rargs := fs.Args()
 
-	notemanager = parseConfig()
+	var err error
+	notemanager, err = parseConfig(optRc, optNotebook)
+	if len(rargs) > 0 && (rargs[0] == "config" || rargs[0] == "notebook") {
+		// the noterc can be repaired with config set
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		if rargs[0] == "config" {
+			configHandler(rargs[1:])
+		} else {
+			notebookHandler(rargs[1:])
+		}
+		os.Exit(0)
+	}
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	if optStrict {
+		notemanager.Strict = true
+	}
 
 	if DirExists(notemanager.DataDir) == false {
 		r := askYesNo(fmt.Sprintf("Notemanager data directory is missing.\nCreate base directory %s?", notemanager.DataDir))
@@ -68,7 +96,7 @@ func main() {
 	// expected cmd syntax: ./note [ FILTER ] cmd args
 	filter, rargs, err := parseFilter(rargs)
 	if err != nil {
-		Exit(`Failed to parse arguments`)
+		Exit(err.Error())
 	}
 
 	if optAll {
@@ -79,7 +107,68 @@ func main() {
 		helpNote()
 	}
 
+	// Commands which modify the store hold the lock of the data
+	// directory, before any note is loaded. add, edit and journal acquire
+	// it themselves after the editor has been closed, template when the
+	// template is written.
+	mutating := []string{
+		"alias",
+		"archive",
+		"copy",
+		"delete",
+		"file",
+		"fsck",
+		"migrate-datadir",
+		"modify",
+		"move",
+		"pin",
+		"restore-archive",
+		"sync",
+		"todo",
+		"unarchive",
+		"undelete",
+		"unpin",
+	}
+	if slices.Contains(mutating, rargs[0]) {
+		if _, err := lockDataDir(); err != nil {
+			Exit(err.Error())
+		}
+	}
+
+	// commands which operate on the data directory itself
+	// and must work even if single notes cannot be loaded
+	switch rargs[0] {
+	case "backup":
+		backupHandler(rargs[1:])
+		os.Exit(0)
+
+	case "fsck":
+		fsckHandler(rargs[1:])
+		os.Exit(0)
+
+	case "migrate-datadir":
+		migrateDataDirHandler(rargs[1:])
+		os.Exit(0)
+
+	case "restore-archive":
+		restoreArchiveHandler(rargs[1:])
+		os.Exit(0)
+
+	case "serve":
+		serveHandler(rargs[1:])
+		os.Exit(0)
+
+	case "sync":
+		syncHandler(rargs[1:])
+		os.Exit(0)
+
+	case "web":
+		webHandler(rargs[1:])
+		os.Exit(0)
+	}
+
 	notes, err := notes(filter)
+	warnNoteErrors()
 
 	/*
 		Handlers which pass the filter should be cleaned up, because we
@@ -92,24 +181,48 @@ func main() {
 	case "add":
 		addHandler(rargs[1:])
 
+	case "agenda":
+		agendaHandler(filter, rargs[1:])
+
 	case "alias":
 		aliasHandler(filter, notes, rargs[1:])
 
+	case "append", "prepend":
+		appendHandler(filter, notes, rargs[0], rargs[1:])
+
+	case "archive":
+		archiveHandler(filter, notes, rargs[1:])
+
+	case "copy", "move":
+		transferHandler(filter, notes, rargs[0], rargs[1:])
+
 	case "delete":
 		deleteHandler(notes, rargs[1:])
 
+	case "drafts":
+		draftsHandler(rargs[1:])
+
 	case "edit":
 		editHandler(notes, rargs[1:])
 
 	case "file":
 		fileHandler(notes, rargs[1:])
 
+	case "journal":
+		journalHandler(rargs[1:])
+
 	case "list":
 		listHandler(filter, rargs[1:])
 
 	case "modify":
 		modifyHandler(filter, notes, rargs[1:])
 
+	case "pin":
+		pinHandler(filter, notes, rargs[1:])
+
+	case "pinned":
+		pinnedHandler(filter, rargs[1:])
+
 	case "print":
 		printHandler(notes, rargs[1:])
 
@@ -125,9 +238,24 @@ func main() {
 	case "tags":
 		tagsHandler(filter, rargs[1:])
 
+	case "template", "templates":
+		templateHandler(rargs[1:])
+
+	case "unarchive":
+		unarchiveHandler(filter, notes, rargs[1:])
+
+	case "todo":
+		todoHandler(notes, rargs[1:])
+
+	case "todos":
+		todosHandler(notes, rargs[1:])
+
 	case "undelete":
 		undeleteHandler(notes, rargs[1:])
 
+	case "unpin":
+		unpinHandler(filter, notes, rargs[1:])
+
 	case "versions":
 		versionsHandler(notes, rargs[1:])
 
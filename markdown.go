@@ -0,0 +1,448 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used by the Markdown renderer
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiDim       = "\x1b[2m"
+	ansiItalic    = "\x1b[3m"
+	ansiUnderline = "\x1b[4m"
+	ansiRed       = "\x1b[31m"
+	ansiGreen     = "\x1b[32m"
+	ansiYellow    = "\x1b[33m"
+	ansiBlue      = "\x1b[34m"
+	ansiMagenta   = "\x1b[35m"
+	ansiCyan      = "\x1b[36m"
+)
+
+var (
+	reAnsi          = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	reMdHeading     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	reMdFence       = regexp.MustCompile("^\\s*(```|~~~)")
+	reMdRule        = regexp.MustCompile(`^\s*((-\s*){3,}|(\*\s*){3,}|(_\s*){3,})$`)
+	reMdList        = regexp.MustCompile(`^(\s*)([-*+]|[0-9]+[.)])\s+(.*)$`)
+	reMdCheckbox    = regexp.MustCompile(`^\[([ xX])\]\s*(.*)$`)
+	reMdQuote       = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	reMdTableRow    = regexp.MustCompile(`^\s*\|.*\|\s*$`)
+	reMdTableSep    = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)+\s*(:?-+:?\s*)?$`)
+	reMdCode        = regexp.MustCompile("`([^`]+)`")
+	reMdBold        = regexp.MustCompile(`(\*\*|__)([^*_]+?)(\*\*|__)`)
+	reMdItalic      = regexp.MustCompile(`(^|[^\pL0-9*_])[*_]([^*_\s][^*_]*?)[*_]([^\pL0-9*_]|$)`)
+	reMdLink        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	reMdAutolink    = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	reMdWhitespaces = regexp.MustCompile(`\s+`)
+	reMdSafeURL     = regexp.MustCompile(`^(https?://|mailto:|[^:]*$)`)
+)
+
+// Render Markdown src for display in a terminal. Text is styled with
+// ANSI escape sequences and wrapped to width.
+func renderMarkdown(src []byte, width int) []byte {
+	if width <= 0 {
+		width = 80
+	}
+
+	var out []string
+	var paragraph []string
+	var table []string
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			text := mdInline(strings.Join(paragraph, " "))
+			out = append(out, wrapAnsi(text, width, "", "")...)
+			paragraph = nil
+		}
+	}
+	flushTable := func() {
+		if len(table) > 0 {
+			out = append(out, mdTable(table)...)
+			table = nil
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if reMdTableRow.MatchString(line) {
+			flushParagraph()
+			table = append(table, line)
+			continue
+		}
+		flushTable()
+
+		// fenced code block, printed verbatim
+		if m := reMdFence.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]) {
+					break
+				}
+				out = append(out, "    "+ansiCyan+lines[i]+ansiReset)
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flushParagraph()
+			out = append(out, "")
+			continue
+		}
+
+		if m := reMdHeading.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			style := ansiBold
+			switch len(m[1]) {
+			case 1:
+				style = ansiBold + ansiUnderline + ansiMagenta
+			case 2:
+				style = ansiBold + ansiBlue
+			case 3:
+				style = ansiBold + ansiCyan
+			}
+			for _, l := range wrapAnsi(mdInline(m[2]), width, "", "") {
+				// restore heading style after styled inline elements
+				out = append(out, style+strings.ReplaceAll(l, ansiReset, ansiReset+style)+ansiReset)
+			}
+			continue
+		}
+
+		if reMdRule.MatchString(line) {
+			flushParagraph()
+			out = append(out, ansiDim+strings.Repeat("─", width)+ansiReset)
+			continue
+		}
+
+		if m := reMdList.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			indent := strings.Repeat(" ", utf8.RuneCountInString(strings.ReplaceAll(m[1], "\t", "    ")))
+			bullet := m[2]
+			if strings.ContainsAny(bullet, "-*+") {
+				bullet = "•"
+			}
+			text := m[3]
+			if c := reMdCheckbox.FindStringSubmatch(text); c != nil {
+				if c[1] == " " {
+					bullet += " " + ansiYellow + "☐" + ansiReset
+				} else {
+					bullet += " " + ansiGreen + "☑" + ansiReset
+				}
+				text = c[2]
+			}
+			first := indent + bullet + " "
+			hanging := indent + strings.Repeat(" ", visibleLength(bullet)+1)
+			out = append(out, wrapAnsi(mdInline(text), width, first, hanging)...)
+			continue
+		}
+
+		if m := reMdQuote.FindStringSubmatch(line); m != nil {
+			flushParagraph()
+			bar := ansiDim + "│ " + ansiReset
+			for _, l := range wrapAnsi(mdInline(m[1]), width, bar, bar) {
+				out = append(out, l)
+			}
+			continue
+		}
+
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+	flushParagraph()
+	flushTable()
+
+	return []byte(strings.Join(out, "\n"))
+}
+
+// style inline elements: code spans, bold and italic text and links
+func mdInline(s string) string {
+	// code spans are not styled any further
+	var codes []string
+	s = reMdCode.ReplaceAllStringFunc(s, func(m string) string {
+		codes = append(codes, ansiRed+m[1:len(m)-1]+ansiReset)
+		return "\x00" + string(rune(len(codes)-1+0xE000)) + "\x00"
+	})
+
+	s = reMdLink.ReplaceAllString(s, ansiUnderline+ansiBlue+"$1"+ansiReset+ansiDim+" ($2)"+ansiReset)
+	s = reMdAutolink.ReplaceAllString(s, ansiUnderline+ansiBlue+"$1"+ansiReset)
+	s = reMdBold.ReplaceAllString(s, ansiBold+"$2"+ansiReset)
+	s = reMdItalic.ReplaceAllString(s, "$1"+ansiItalic+"$2"+ansiReset+"$3")
+
+	for i, c := range codes {
+		s = strings.Replace(s, "\x00"+string(rune(i+0xE000))+"\x00", c, 1)
+	}
+	return s
+}
+
+// render table rows with aligned columns
+func mdTable(rows []string) (out []string) {
+	var cells [][]string
+	var widths []int
+	separator := -1
+	for i, row := range rows {
+		if reMdTableSep.MatchString(row) {
+			separator = i
+			cells = append(cells, nil)
+			continue
+		}
+
+		row = strings.TrimSpace(row)
+		row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+		var r []string
+		for j, c := range strings.Split(row, "|") {
+			c = mdInline(strings.TrimSpace(c))
+			r = append(r, c)
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if l := visibleLength(c); l > widths[j] {
+				widths[j] = l
+			}
+		}
+		cells = append(cells, r)
+	}
+
+	border := ansiDim + "│" + ansiReset
+	for i, r := range cells {
+		if i == separator {
+			var parts []string
+			for _, w := range widths {
+				parts = append(parts, strings.Repeat("─", w+2))
+			}
+			out = append(out, ansiDim+"├"+strings.Join(parts, "┼")+"┤"+ansiReset)
+			continue
+		}
+
+		line := border
+		for j, w := range widths {
+			var c string
+			if j < len(r) {
+				c = r[j]
+			}
+			if i < separator {
+				c = ansiBold + c + ansiReset
+			}
+			line += " " + c + strings.Repeat(" ", w-visibleLength(c)) + " " + border
+		}
+		out = append(out, line)
+	}
+
+	return
+}
+
+// returns number of characters of s without ANSI escape sequences
+func visibleLength(s string) int {
+	return utf8.RuneCountInString(reAnsi.ReplaceAllString(s, ""))
+}
+
+// Wrap text containing ANSI escape sequences to width. The first line is
+// prefixed by first, all following lines by hanging.
+func wrapAnsi(text string, width int, first string, hanging string) (lines []string) {
+	line := first
+	empty := true
+	for _, word := range reMdWhitespaces.Split(strings.TrimSpace(text), -1) {
+		if word == "" {
+			continue
+		}
+		if empty == false && visibleLength(line)+1+visibleLength(word) > width {
+			lines = append(lines, line)
+			line = hanging
+			empty = true
+		}
+		if empty == false {
+			line += " "
+		}
+		line += word
+		empty = false
+	}
+	return append(lines, line)
+}
+
+// Render Markdown src as HTML, e.g. for note web. Supports the same
+// elements as renderMarkdown, all text is escaped.
+func renderMarkdownHTML(src []byte) string {
+	var out []string
+	var paragraph []string
+	var table []string
+	var list []string
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out = append(out, "<p>"+mdInlineHTML(strings.Join(paragraph, " "))+"</p>")
+			paragraph = nil
+		}
+	}
+	flushTable := func() {
+		if len(table) > 0 {
+			out = append(out, mdTableHTML(table))
+			table = nil
+		}
+	}
+	flushList := func() {
+		if len(list) > 0 {
+			out = append(out, `<ul class="md-list">`+strings.Join(list, "")+"</ul>")
+			list = nil
+		}
+	}
+	flush := func() {
+		flushParagraph()
+		flushTable()
+		flushList()
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if reMdTableRow.MatchString(line) {
+			flushParagraph()
+			flushList()
+			table = append(table, line)
+			continue
+		}
+		flushTable()
+
+		// list items are continued by items only
+		m := reMdList.FindStringSubmatch(line)
+		if m == nil {
+			flushList()
+		}
+
+		// fenced code block, printed verbatim
+		if f := reMdFence.FindStringSubmatch(line); f != nil {
+			flush()
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), f[1]) {
+					break
+				}
+				code = append(code, html.EscapeString(lines[i]))
+			}
+			out = append(out, "<pre><code>"+strings.Join(code, "\n")+"</code></pre>")
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		if h := reMdHeading.FindStringSubmatch(line); h != nil {
+			flush()
+			out = append(out, fmt.Sprintf("<h%d>%s</h%d>", len(h[1]), mdInlineHTML(h[2]), len(h[1])))
+			continue
+		}
+
+		if reMdRule.MatchString(line) {
+			flush()
+			out = append(out, "<hr>")
+			continue
+		}
+
+		if m != nil {
+			flushParagraph()
+			indent := utf8.RuneCountInString(strings.ReplaceAll(m[1], "\t", "    "))
+			bullet := html.EscapeString(m[2])
+			if strings.ContainsAny(bullet, "-*+") {
+				bullet = "•"
+			}
+			text := m[3]
+			if c := reMdCheckbox.FindStringSubmatch(text); c != nil {
+				if c[1] == " " {
+					bullet += ` <span class="todo">☐</span>`
+				} else {
+					bullet += ` <span class="done">☑</span>`
+				}
+				text = c[2]
+			}
+			list = append(list, fmt.Sprintf(`<li style="margin-left: %.1fem"><span class="bullet">%s</span> %s</li>`, float64(indent)/2, bullet, mdInlineHTML(text)))
+			continue
+		}
+
+		if q := reMdQuote.FindStringSubmatch(line); q != nil {
+			flushParagraph()
+			out = append(out, "<blockquote>"+mdInlineHTML(q[1])+"</blockquote>")
+			continue
+		}
+
+		paragraph = append(paragraph, strings.TrimSpace(line))
+	}
+	flush()
+
+	return strings.Join(out, "\n")
+}
+
+// escape text and render inline elements as HTML: code spans, bold and
+// italic text and links
+func mdInlineHTML(s string) string {
+	// code spans and links are kept as placeholders, so their content
+	// is neither escaped twice nor styled
+	var parts []string
+	placeholder := func(p string) string {
+		parts = append(parts, p)
+		return "\x00" + string(rune(len(parts)-1+0xE000)) + "\x00"
+	}
+
+	s = reMdCode.ReplaceAllStringFunc(s, func(m string) string {
+		return placeholder("<code>" + html.EscapeString(m[1:len(m)-1]) + "</code>")
+	})
+	s = reMdLink.ReplaceAllStringFunc(s, func(m string) string {
+		l := reMdLink.FindStringSubmatch(m)
+		if reMdSafeURL.MatchString(l[2]) == false {
+			return placeholder(html.EscapeString(m))
+		}
+		return placeholder(fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(l[2]), html.EscapeString(l[1])))
+	})
+	s = reMdAutolink.ReplaceAllStringFunc(s, func(m string) string {
+		url := html.EscapeString(m[1 : len(m)-1])
+		return placeholder(fmt.Sprintf(`<a href="%s">%s</a>`, url, url))
+	})
+
+	s = html.EscapeString(s)
+	s = reMdBold.ReplaceAllString(s, "<strong>$2</strong>")
+	s = reMdItalic.ReplaceAllString(s, "$1<em>$2</em>$3")
+
+	for i, p := range parts {
+		s = strings.Replace(s, "\x00"+string(rune(i+0xE000))+"\x00", p, 1)
+	}
+	return s
+}
+
+// render table rows as HTML table, rows before the separator are the head
+func mdTableHTML(rows []string) string {
+	separator := -1
+	for i, row := range rows {
+		if reMdTableSep.MatchString(row) {
+			separator = i
+			break
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<table>")
+	for i, row := range rows {
+		if i == separator {
+			continue
+		}
+		cell := "td"
+		if i < separator {
+			cell = "th"
+		}
+
+		row = strings.TrimSpace(row)
+		row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+		b.WriteString("<tr>")
+		for _, c := range strings.Split(row, "|") {
+			fmt.Fprintf(&b, "<%s>%s</%s>", cell, mdInlineHTML(strings.TrimSpace(c)), cell)
+		}
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</table>")
+
+	return b.String()
+}
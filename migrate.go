@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CMD: note migrate-datadir NEW
+func migrateDataDirHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note migrate-datadir", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteMigrateDataDir() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() != 1 {
+		helpNoteMigrateDataDir()
+	}
+
+	dst, err := parsePathOption(fs.Arg(0))
+	if err == nil {
+		dst, err = filepath.Abs(dst)
+	}
+	if err == nil {
+		err = migrateDataDir(dst)
+	}
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// Move the data directory to dst. All files are copied and verified by
+// their checksums, before the noterc is updated and the old data
+// directory is removed. If anything fails, the old data directory
+// is left untouched.
+func migrateDataDir(dst string) (err error) {
+	// the noterc is not used for the data directory of NOTE_DATADIR
+	if os.Getenv("NOTE_DATADIR") != "" && notemanager.Notebook == "" {
+		return errors.New("NOTE_DATADIR is set and overrides the datadir of the noterc, unset it to migrate")
+	}
+
+	src, err := filepath.Abs(notemanager.DataDir)
+	if err != nil {
+		return
+	}
+
+	if dst == src || strings.HasPrefix(dst, src+string(filepath.Separator)) {
+		return errors.New("New data directory must not be inside of " + src)
+	}
+
+	if files, err := os.ReadDir(dst); err == nil && len(files) > 0 {
+		return errors.New("New data directory is not empty: " + dst)
+	}
+
+	fmt.Printf("Copying %s to %s\n", src, dst)
+	manifest, err := copyDataDir(src, dst)
+	if err != nil {
+		return
+	}
+
+	err = manifest.Verify(dst)
+	if err != nil {
+		return fmt.Errorf("Verification of %s failed, %s is kept: %s", dst, src, err)
+	}
+	fmt.Printf("Verified %d files.\n", len(manifest.Files))
+
+	// the data directory of a notebook other than the default one
+	// is set by its own key
+	key := "datadir"
+	if notemanager.Notebook != "" && notemanager.Notebook != defaultNotebook {
+		key = notebookDataDirOption(notemanager.Notebook).Key
+	}
+
+	err = writeNotercOption(notemanager.NotercPath, key, dst)
+	if err != nil {
+		return fmt.Errorf("Failed to update %s, %s is kept: %s", notemanager.NotercPath, src, err)
+	}
+	fmt.Printf("Updated %s in %s\n", key, notemanager.NotercPath)
+
+	err = os.RemoveAll(src)
+	if err != nil {
+		return
+	}
+	fmt.Printf("Removed %s\n", src)
+
+	return
+}
+
+// Copy all files of data directory src to dst, keeping permissions and
+// modification times. Returns the checksums of the copied files. Fails
+// before copying, if src contains other than regular files, e.g.
+// symlinks, which would be lost when src is removed afterwards.
+func copyDataDir(src string, dst string) (manifest BackupManifest, err error) {
+	manifest = BackupManifest{
+		Version: 1,
+		Created: time.Now().UTC(),
+		Files:   make(map[string]string),
+	}
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() == false && d.Type().IsRegular() == false {
+			err = errors.New("Not a regular file, move or remove it first: " + path)
+		}
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+
+		// the lock belongs to the old data directory
+		if rel == ".lock" {
+			return nil
+		}
+
+		sum, err := fileSha1(path)
+		if err != nil {
+			return err
+		}
+		manifest.Files[filepath.ToSlash(rel)] = sum
+
+		return copyFile(path, target, info)
+	})
+
+	return
+}
+
+// copy regular file src to dst with mode and modification time of info
+func copyFile(src string, dst string, info fs.FileInfo) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+
+	err = os.Chmod(dst, info.Mode().Perm())
+	if err != nil {
+		return
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
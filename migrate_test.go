@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMigrateDataDir(t *testing.T) {
+	t.Setenv("NOTE_DATADIR", "")
+	src := testDataDir(t)
+	notemanager.NotercPath = filepath.Join(t.TempDir(), "noterc")
+	if err := os.WriteFile(notemanager.NotercPath, []byte("datadir = "+src+"\neditor = vi\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Migrate",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "content\n")
+
+	dst := filepath.Join(t.TempDir(), "new")
+	if err := migrateDataDir(dst); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(src); err == nil {
+		t.Errorf("old data directory %s not removed", src)
+	}
+	content, err := os.ReadFile(filepath.Join(dst, "notes", n.Id.String(), "20260101-100000"))
+	if err != nil || string(content) != "content\n" {
+		t.Errorf("migrated version = %q, %v", content, err)
+	}
+	rc, err := os.ReadFile(notemanager.NotercPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "datadir = " + dst + "\neditor = vi\n"; string(rc) != want {
+		t.Errorf("noterc = %q, want %q", rc, want)
+	}
+}
+
+func TestMigrateDataDirIntoItself(t *testing.T) {
+	t.Setenv("NOTE_DATADIR", "")
+	src := testDataDir(t)
+	notemanager.NotercPath = filepath.Join(t.TempDir(), "noterc")
+
+	err := migrateDataDir(filepath.Join(src, "new"))
+	if err == nil || strings.Contains(err.Error(), "must not be inside") == false {
+		t.Errorf("migrate into data directory: %v", err)
+	}
+	if _, err = os.Stat(notemanager.NotercPath); err == nil {
+		t.Error("noterc written")
+	}
+}
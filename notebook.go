@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// name of the notebook of the datadir setting
+const defaultNotebook = "default"
+
+// notebook names are lower case, as keys of the noterc are
+var reNotebookName = regexp.MustCompile(`^[a-z0-9_\-]+$`)
+var reNotebookKey = regexp.MustCompile(`^notebook\.([a-z0-9_\-]+)\.datadir$`)
+
+// option of the data directory of notebook name
+func notebookDataDirOption(name string) ConfigOption {
+	return ConfigOption{
+		Key:         "notebook." + name + ".datadir",
+		Description: "Data directory of notebook " + name,
+		get:         func(c *Config) string { return c.Notebooks[name] },
+		set: func(c *Config, v string) (err error) {
+			if name == defaultNotebook {
+				return errors.New("use datadir for the default notebook")
+			}
+			if c.Notebooks == nil {
+				c.Notebooks = make(map[string]string)
+			}
+			c.Notebooks[name], err = parsePathOption(v)
+			return
+		},
+	}
+}
+
+// returns names of all notebooks, sorted
+func notebookNames() (names []string) {
+	for name := range notemanager.Notebooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// returns configuration of notebook name
+func notebookConfig(name string) (c Config, err error) {
+	dir, ok := notemanager.Notebooks[strings.ToLower(name)]
+	if ok == false {
+		err = errors.New("Unknown notebook: " + name)
+		return
+	}
+
+	c = notemanager
+	c.Notebook = strings.ToLower(name)
+	c.DataDir = dir
+	c.AliasesPath = filepath.Clean(c.DataDir + "/aliases")
+	c.TemplateDir = filepath.Clean(c.DataDir + "/templates")
+	c.TempDir = filepath.Clean(c.DataDir + "/tmp")
+	c.NoteDir = filepath.Clean(c.DataDir + "/notes")
+	return
+}
+
+// CMD: note notebook [list|use NAME]
+func notebookHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note notebook", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteNotebook() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp {
+		helpNoteNotebook()
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		current := notemanager.Notebook
+		if current == "" && os.Getenv("NOTE_DATADIR") == "" {
+			current = defaultNotebook
+		}
+		for _, name := range notebookNames() {
+			mark := " "
+			if name == current {
+				mark = "*"
+			}
+			fmt.Printf("%s %-12s %s\n", mark, name, notemanager.Notebooks[name])
+		}
+
+	case args[0] == "use" && len(args) == 2:
+		name := strings.ToLower(args[1])
+		if _, ok := notemanager.Notebooks[name]; ok == false {
+			Exit("Unknown notebook: " + args[1])
+		}
+		err = writeNotercOption(notemanager.NotercPath, notebookOption.Key, name)
+		if err == nil {
+			fmt.Printf("Using notebook %s.\n", name)
+		}
+
+	default:
+		helpNoteNotebook()
+	}
+
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// CMD: note FILTER copy|move --to NOTEBOOK
+// mode is either copy or move
+func transferHandler(filter NoteFilter, notes []Note, mode string, args []string) (err error) {
+	var optHelp bool
+	var optTo string
+	fs := flag.NewFlagSet("note "+mode, flag.ContinueOnError)
+	fs.Usage = func() { helpNoteTransfer() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.StringVar(&optTo, "to", "", "Target notebook")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || optTo == "" || fs.NArg() > 0 {
+		helpNoteTransfer()
+	}
+
+	if err = requireSelection(filter, notes); err != nil {
+		Exit(err.Error())
+	}
+
+	err = transferNotes(notes, optTo, mode == "move")
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// Copy notes with all versions, attachments and aliases to notebook to.
+// If move is set, the notes are removed from the current notebook, once
+// the copies have been verified.
+func transferNotes(notes []Note, to string, move bool) (err error) {
+	target, err := notebookConfig(to)
+	if err != nil {
+		return
+	}
+
+	if filepath.Clean(target.DataDir) == filepath.Clean(notemanager.DataDir) {
+		return errors.New("Notes are already in notebook " + target.Notebook)
+	}
+
+	if DirExists(target.NoteDir) == false {
+		return fmt.Errorf("Notebook %s is not initialized, run: note -n %s list", target.Notebook, target.Notebook)
+	}
+
+	lock, err := lockDir(target.DataDir)
+	if err != nil {
+		return
+	}
+	defer lock.Close()
+
+	targetAliases, err := readAliases(target.AliasesPath)
+	if err != nil && errors.Is(err, os.ErrNotExist) == false {
+		return
+	}
+
+	for _, n := range notes {
+		dst := filepath.Join(target.NoteDir, n.Id.String())
+		if _, err = os.Stat(dst); err == nil {
+			return fmt.Errorf("%s: Note already exists in notebook %s", n.ShortId(), target.Notebook)
+		}
+
+		var manifest BackupManifest
+		manifest, err = copyDataDir(n.Path(), dst)
+		if err == nil {
+			err = manifest.Verify(dst)
+		}
+		if err != nil {
+			os.RemoveAll(dst)
+			return fmt.Errorf("%s: Copy failed: %s", n.ShortId(), err)
+		}
+
+		for _, alias := range aliases.FindById(n.Id) {
+			if id, exists := targetAliases.Get(alias); exists && id != n.Id {
+				fmt.Printf("%s: Alias %s exists in notebook %s, not transferred\n", n.ShortId(), alias, target.Notebook)
+				if n.Alias == alias {
+					n.Alias = ""
+					err = writeFileAtomic(filepath.Join(dst, "data"), n.Yaml(), notemanager.FilePermission)
+					if err != nil {
+						return
+					}
+				}
+				continue
+			}
+			targetAliases[alias] = n.Id
+		}
+
+		err = targetAliases.WriteFile(target.AliasesPath)
+		if err != nil {
+			return
+		}
+
+		if move == false {
+			fmt.Printf("%s: Copied to notebook %s\n", n.ShortId(), target.Notebook)
+			continue
+		}
+
+		aliases.DeleteById(n.Id)
+		aliases.Write()
+		err = os.RemoveAll(n.Path())
+		if err != nil {
+			return
+		}
+		fmt.Printf("%s: Moved to notebook %s\n", n.ShortId(), target.Notebook)
+	}
+
+	return
+}
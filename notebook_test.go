@@ -0,0 +1,154 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// add notebook name with an initialized data directory
+func testNotebook(t *testing.T, name string) Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	if notemanager.Notebooks == nil {
+		notemanager.Notebooks = map[string]string{defaultNotebook: notemanager.DataDir}
+	}
+	notemanager.Notebooks[name] = dir
+
+	c, err := notebookConfig(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(c.NoteDir, notemanager.DirPermission); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestTransferNotes(t *testing.T) {
+	testDataDir(t)
+	target := testNotebook(t, "other")
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Transfer",
+		Alias:       "plan",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000", "20260102-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "first\n", "second\n")
+	file := filepath.Join(t.TempDir(), "doc.txt")
+	if err := os.WriteFile(file, []byte("attachment\n"), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Attach(file, "doc.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// alias of m refers to another note in the target notebook
+	m := Note{
+		Id:          uuid.New(),
+		Title:       "Taken alias",
+		Alias:       "meeting",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, m, "meeting\n")
+
+	aliases.Set("plan", n.Id)
+	aliases.Set("meeting", m.Id)
+	aliases.Write()
+	other := uuid.New()
+	if err := (&NoteAliases{"meeting": other}).WriteFile(target.AliasesPath); err != nil {
+		t.Fatal(err)
+	}
+
+	var selected []Note
+	for _, x := range []Note{n, m} {
+		loaded, err := loadNote(x.Id.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		selected = append(selected, loaded)
+	}
+
+	if err := transferNotes(selected, "other", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// versions and attachments are in the target notebook
+	dst := filepath.Join(target.NoteDir, n.Id.String())
+	for name, want := range map[string]string{
+		"20260101-100000":     "first\n",
+		"20260102-100000":     "second\n",
+		"attachments/doc.txt": "attachment\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil || string(content) != want {
+			t.Errorf("%s = %q, %v, want %q", name, content, err, want)
+		}
+	}
+	side, err := readSyncSide(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(side.note.Versions, n.Versions) == false || len(side.note.Attachments) != 1 || side.note.Alias != "plan" {
+		t.Errorf("transferred note %+v", side.note)
+	}
+
+	// aliases are transferred, unless taken
+	targetAliases, err := readAliases(target.AliasesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targetAliases["plan"] != n.Id || targetAliases["meeting"] != other {
+		t.Errorf("target aliases %v", targetAliases)
+	}
+	side, err = readSyncSide(filepath.Join(target.NoteDir, m.Id.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if side.note.Alias != "" {
+		t.Errorf("taken alias %s kept in data file", side.note.Alias)
+	}
+
+	// moved notes are removed
+	for _, x := range []Note{n, m} {
+		if DirExists(x.Path()) {
+			t.Errorf("%s not removed", x.Path())
+		}
+	}
+	if len(aliases) != 0 {
+		t.Errorf("aliases left: %v", aliases)
+	}
+}
+
+func TestCopyNotesKeepsSource(t *testing.T) {
+	testDataDir(t)
+	target := testNotebook(t, "other")
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Copy",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "copy\n")
+
+	if err := transferNotes([]Note{n}, "other", false); err != nil {
+		t.Fatal(err)
+	}
+	if DirExists(n.Path()) == false || DirExists(filepath.Join(target.NoteDir, n.Id.String())) == false {
+		t.Error("note not in both notebooks")
+	}
+
+	// the copy exists already
+	if err := transferNotes([]Note{n}, "other", false); err == nil {
+		t.Error("copy over existing note succeeded")
+	}
+}
@@ -11,13 +11,13 @@ import (
 	"os/exec"
 	"path/filepath"
 
-	"github.com/gosimple/conf"
+	"golang.org/x/sys/unix"
 	"golang.org/x/term"
 )
 
-func parseConfig() (c Config) {
-	var cfgExists bool
-
+// returns the default configuration of the platform. Settings of the
+// noterc and environment variables are applied by parseConfig.
+func defaultConfig() (c Config) {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal(err)
@@ -49,58 +49,50 @@ func parseConfig() (c Config) {
 	// Pagination Reader (Default: less)
 	c.TerminalReader = `less`
 
+	// render Markdown in the terminal
+	c.RenderMarkdown = true
+
+	// template of the note header of read and print
+	c.OutputTemplate = "output"
+
+	// notes are Markdown, so editors highlight the syntax
+	c.TempFileExtension = ".md"
+
 	// default data directory
-	c.DataDir = filepath.Clean(homedir + "/.notes")
+	c.DataDir = xdgPath("XDG_DATA_HOME", homedir+"/.local/share", "notemanager", homedir+"/.notes")
 
 	// default noterc path
-	c.NotercPath = filepath.Clean(homedir + `/.noterc`)
-
-	// Path of aliases file
-	c.AliasesPath = filepath.Clean(c.DataDir + `/aliases`)
+	c.NotercPath = xdgPath("XDG_CONFIG_HOME", homedir+"/.config", "notemanager/noterc", homedir+"/.noterc")
 
 	// set default editor
 	editors := []string{"nano", "nvim", "vim", "vi", "emacs", "ed"}
 	for _, editor := range editors {
 		path, err := exec.LookPath(editor)
 		if err == nil {
-			c.Editor = filepath.Clean(path)
+			c.Editor = quoteCommandArg(filepath.Clean(path))
 			break
 		}
 	}
 
-	cfg, err := conf.ReadFile(c.NotercPath)
-	if err == nil {
-		cfgExists = true
-	} else {
-		cfgExists = false
-	}
-
-	if cfgExists {
-		datadir, err := cfg.String("default", "datadir")
-		if err == nil {
-			c.DataDir = filepath.Clean(datadir)
-		}
-
-		editor, err := cfg.String("default", "editor")
-		if err == nil {
-			c.Editor = filepath.Clean(editor)
-		}
+	return
+}
 
-		terminalReader, err := cfg.String("default", "terminalReader")
-		if err == nil {
-			c.TerminalReader = filepath.Clean(terminalReader)
-		}
+// Returns path name inside of the XDG base directory of environment
+// variable env, or base if the variable is not set. If only the legacy
+// path exists, it is used instead.
+func xdgPath(env string, base string, name string, legacy string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		base = dir
 	}
+	path := filepath.Clean(base + "/" + name)
 
-	c.TemplateDir = filepath.Clean(c.DataDir + "/templates")
-	c.TempDir = filepath.Clean(c.DataDir + "/tmp")
-	c.NoteDir = filepath.Clean(c.DataDir + "/notes")
-
-	if c.Editor == "" {
-		log.Fatal("Please define a text editor")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil {
+			return filepath.Clean(legacy)
+		}
 	}
 
-	return
+	return path
 }
 
 func runFileManager(path string) {
@@ -124,3 +116,25 @@ func terminalWidth() (width int) {
 	width, _, _ = term.GetSize(0)
 	return
 }
+
+// Acquire an exclusive advisory lock on file f. If block is false and
+// the lock is held by another process, errLocked is returned.
+func lockFile(f *os.File, block bool) (err error) {
+	how := unix.LOCK_EX
+	if block == false {
+		how |= unix.LOCK_NB
+	}
+
+	err = unix.Flock(int(f.Fd()), how)
+	if err == unix.EWOULDBLOCK {
+		err = errLocked
+	}
+	return
+}
+
+// Checks if process pid is running. Signal 0 only checks if the
+// process exists, EPERM is returned for processes of other users.
+func processRunning(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
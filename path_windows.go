@@ -11,13 +11,13 @@ import (
 	"os/exec"
 	"path/filepath"
 
-	"github.com/gosimple/conf"
+	"golang.org/x/sys/windows"
 	"golang.org/x/term"
 )
 
-func parseConfig() (c Config) {
-	var cfgExists bool
-
+// returns the default configuration of the platform. Settings of the
+// noterc and environment variables are applied by parseConfig.
+func defaultConfig() (c Config) {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal(err)
@@ -50,59 +50,32 @@ func parseConfig() (c Config) {
 	// Pagination Reader (Default: more)
 	c.TerminalReader = `more`
 
+	// render Markdown in the terminal
+	c.RenderMarkdown = true
+
+	// template of the note header of read and print
+	c.OutputTemplate = "output"
+
+	// notes are Markdown, so editors highlight the syntax
+	c.TempFileExtension = ".md"
+
 	// default data directory
 	c.DataDir = filepath.Clean(homedir + `/AppData/Roaming/Notemanager`)
 
 	// default noterc path
 	c.NotercPath = filepath.Clean(homedir + `/AppData/Roaming/Notemanager/noterc`)
 
-	// Path of aliases file
-	c.AliasesPath = filepath.Clean(c.DataDir + `/aliases`)
-
 	//c.Editor = `notepad`
 	// set default editor
 	editors := []string{"nvim", "gvim", "notepad"}
 	for _, editor := range editors {
 		path, err := exec.LookPath(editor)
 		if err == nil {
-			c.Editor = filepath.Clean(path)
+			c.Editor = quoteCommandArg(filepath.Clean(path))
 			break
 		}
 	}
 
-	cfgExists = false
-	cfg, err := conf.ReadFile(c.NotercPath)
-	if err == nil {
-		cfgExists = true
-	}
-
-	if cfgExists {
-		datadir, err := cfg.String("default", "datadir")
-		if err == nil {
-			c.DataDir = filepath.Clean(datadir)
-		}
-
-		editor, err := cfg.String("default", "editor")
-		if err == nil {
-			c.Editor = filepath.Clean(editor)
-		}
-
-		// Default to more.exe as reader with pagination
-		terminalReader, err := cfg.String("default", "terminalReader")
-		if err == nil {
-			c.TerminalReader = filepath.Clean(terminalReader)
-		}
-
-	}
-
-	c.TemplateDir = filepath.Clean(c.DataDir + `/templates`)
-	c.TempDir = filepath.Clean(c.DataDir + `/tmp`)
-	c.NoteDir = filepath.Clean(c.DataDir + `/notes`)
-
-	if c.Editor == "" {
-		log.Fatal("Please define a text editor")
-	}
-
 	return
 }
 
@@ -129,3 +102,33 @@ func terminalWidth() (width int) {
 	width, _, _ = term.GetSize(0)
 	return
 }
+
+// Acquire an exclusive advisory lock on file f. If block is false and
+// the lock is held by another process, errLocked is returned.
+func lockFile(f *os.File, block bool) (err error) {
+	var flags uint32 = windows.LOCKFILE_EXCLUSIVE_LOCK
+	if block == false {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+
+	ol := new(windows.Overlapped)
+	err = windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, ol)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		err = errLocked
+	}
+	return
+}
+
+// Checks if process pid is running, i.e. has no exit code yet
+func processRunning(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+
+	// STILL_ACTIVE
+	var code uint32
+	err = windows.GetExitCodeProcess(h, &code)
+	return err != nil || code == 259
+}
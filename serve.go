@@ -0,0 +1,588 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// note as returned by the API
+type apiNote struct {
+	Id          string            `json:"id"`
+	Title       string            `json:"title"`
+	Alias       string            `json:"alias,omitempty"`
+	Tags        []string          `json:"tags"`
+	VirtualTags []string          `json:"virtualTags"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Versions    []string          `json:"versions"`
+	Attachments []apiAttachment   `json:"attachments"`
+	Pinned      bool              `json:"pinned,omitempty"`
+	Priority    int               `json:"priority,omitempty"`
+	Created     time.Time         `json:"created"`
+	Modified    *time.Time        `json:"modified,omitempty"`
+	Deleted     *time.Time        `json:"deleted,omitempty"`
+	Archived    *time.Time        `json:"archived,omitempty"`
+	Due         *time.Time        `json:"due,omitempty"`
+	Review      *time.Time        `json:"review,omitempty"`
+}
+
+type apiAttachment struct {
+	Filename string    `json:"filename"`
+	Sha1     string    `json:"sha1"`
+	Created  time.Time `json:"created"`
+}
+
+// search match as returned by the API
+type apiSearchMatch struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+	Lines []int  `json:"lines"`
+}
+
+// body of POST /notes
+type apiCreateRequest struct {
+	Title    string   `json:"title"`
+	Tags     []string `json:"tags"`
+	Content  string   `json:"content"`
+	Template string   `json:"template"`
+}
+
+// body of PATCH /notes/ID
+type apiModifyRequest struct {
+	Title      *string           `json:"title"`
+	AddTags    []string          `json:"addTags"`
+	RemoveTags []string          `json:"removeTags"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// maximum size of request bodies with JSON or text
+const apiMaxBodySize = 1 << 20
+
+// maximum size of uploaded attachments
+const apiMaxUploadSize = 100 << 20
+
+// error of a request, which is returned with status code
+type apiError struct {
+	status int
+	err    error
+}
+
+func (e apiError) Error() string {
+	return e.err.Error()
+}
+
+// returns error of reading a request body, which is too large or
+// invalid
+func apiBodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apiError{http.StatusRequestEntityTooLarge, err}
+	}
+	return apiError{http.StatusBadRequest, err}
+}
+
+// The API server handles one request at a time, as notes are read and
+// written through the global state of the process.
+type apiServer struct {
+	mu    sync.Mutex
+	token string
+}
+
+// CMD: note serve [--listen ADDR]
+func serveHandler(args []string) (err error) {
+	var optHelp bool
+	var optListen string
+	fs := flag.NewFlagSet("note serve", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteServe() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.StringVar(&optListen, "l", "127.0.0.1:8080", "Listen address")
+	fs.StringVar(&optListen, "listen", "127.0.0.1:8080", "Listen address")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() > 0 {
+		helpNoteServe()
+	}
+
+	if notemanager.ServeToken == "" {
+		Exit("No API token set, set one with: note config set serveToken TOKEN")
+	}
+
+	host, _, err := net.SplitHostPort(optListen)
+	if err != nil {
+		Exit(err.Error())
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || ip.IsLoopback() == false) {
+		fmt.Fprintln(os.Stderr, "Warning: The API is served without TLS, the token is sent in clear text.")
+	}
+
+	server := &http.Server{
+		Addr:              optListen,
+		Handler:           newAPIServer(notemanager.ServeToken),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("Serving notes of %s on http://%s\n", notemanager.DataDir, optListen)
+	err = server.ListenAndServe()
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// returns handler of the API, which requires token
+func newAPIServer(token string) http.Handler {
+	return &apiServer{token: token}
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="note"`)
+		writeAPIError(w, apiError{http.StatusUnauthorized, errors.New("Invalid token")})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// aliases may have been changed by other processes
+	var err error
+	aliases, err = readAliases(notemanager.AliasesPath)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		unlock, err := lockDataDir()
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		defer unlock()
+	}
+
+	err = s.route(w, r)
+	if err != nil {
+		writeAPIError(w, err)
+	}
+}
+
+// dispatch request by method and path
+func (s *apiServer) route(w http.ResponseWriter, r *http.Request) (err error) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	route := r.Method + " " + path[0]
+
+	var n Note
+	if path[0] == "notes" && len(path) > 1 {
+		n, err = apiFindNote(path[1])
+		if err != nil {
+			return
+		}
+		route += "/ID"
+		if len(path) > 2 {
+			route += "/" + path[2]
+		}
+		if len(path) > 3 {
+			route += "/NAME"
+		}
+		if len(path) > 4 {
+			route += "/..."
+		}
+	}
+
+	switch route {
+	case "GET notes":
+		return apiListNotes(w, r)
+
+	case "POST notes":
+		return apiCreateNote(w, r)
+
+	case "GET notes/ID":
+		return writeJSON(w, http.StatusOK, newAPINote(n))
+
+	case "PATCH notes/ID":
+		return apiModifyNote(w, r, n)
+
+	case "DELETE notes/ID":
+		if err = n.Delete(); err != nil {
+			return
+		}
+		return apiWriteNote(w, http.StatusOK, n.Id)
+
+	case "POST notes/ID/undelete":
+		if err = n.Undelete(); err != nil {
+			return
+		}
+		return apiWriteNote(w, http.StatusOK, n.Id)
+
+	case "GET notes/ID/content":
+		return apiNoteContent(w, r, n)
+
+	case "GET notes/ID/versions":
+		return writeJSON(w, http.StatusOK, n.Versions)
+
+	case "POST notes/ID/append", "POST notes/ID/prepend":
+		return apiAppendNote(w, r, n, path[2])
+
+	case "GET notes/ID/attachments":
+		return writeJSON(w, http.StatusOK, newAPINote(n).Attachments)
+
+	case "GET notes/ID/attachments/NAME":
+		return apiDownloadAttachment(w, r, n, path[3])
+
+	case "PUT notes/ID/attachments/NAME":
+		return apiUploadAttachment(w, r, n, path[3])
+
+	case "GET search":
+		return apiSearch(w, r)
+	}
+
+	return apiError{http.StatusNotFound, errors.New("Not found: " + r.Method + " " + r.URL.Path)}
+}
+
+// returns single note selected by id, short id or alias
+func apiFindNote(id string) (n Note, err error) {
+	filter, rargs, err := parseFilter([]string{id})
+	if err != nil || len(rargs) > 0 || len(filter.Notes) == 0 {
+		return n, apiError{http.StatusNotFound, errors.New("Note not found: " + id)}
+	}
+
+	found, err := notes(filter)
+	switch {
+	case err != nil:
+		return
+	case len(found) == 0:
+		return n, apiError{http.StatusNotFound, errors.New("Note not found: " + id)}
+	case len(found) > 1:
+		return n, apiError{http.StatusConflict, errors.New("Note id is ambiguous: " + id)}
+	}
+
+	return found[0], nil
+}
+
+// returns filter of the query parameter filter, which has the syntax of
+// the command line, e.g. filter=+work -done
+func apiFilter(r *http.Request) (filter NoteFilter, err error) {
+	args, err := splitCommandLine(r.URL.Query().Get("filter"))
+	if err != nil {
+		return filter, apiError{http.StatusBadRequest, err}
+	}
+
+	filter, rargs, err := parseFilter(args)
+	if err == nil && len(rargs) > 0 {
+		err = errors.New("Invalid filter term: " + rargs[0])
+	}
+	if err != nil {
+		return filter, apiError{http.StatusBadRequest, err}
+	}
+
+	if r.URL.Query().Get("all") == "true" {
+		filter.IncludeDeleted = true
+	}
+	return
+}
+
+// GET /notes?filter=TERMS[&all=true]
+func apiListNotes(w http.ResponseWriter, r *http.Request) (err error) {
+	filter, err := apiFilter(r)
+	if err != nil {
+		return
+	}
+
+	found, err := notes(filter)
+	if err != nil {
+		return
+	}
+
+	list := make([]apiNote, 0, len(found))
+	for _, n := range found {
+		list = append(list, newAPINote(n))
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+// POST /notes
+func apiCreateNote(w http.ResponseWriter, r *http.Request) (err error) {
+	var req apiCreateRequest
+	r.Body = http.MaxBytesReader(w, r.Body, apiMaxBodySize)
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apiBodyError(err)
+	}
+
+	note := Note{
+		Id:          uuid.New(),
+		Title:       req.Title,
+		DateCreated: time.Now().UTC(),
+	}
+	for _, t := range req.Tags {
+		if err = note.AddTag(t); err != nil {
+			return apiError{http.StatusBadRequest, err}
+		}
+	}
+
+	if req.Template == "" {
+		req.Template = "note"
+	}
+	content := []byte(req.Content)
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+
+	err = addNoteFromTemplate(note, req.Template, content, true)
+	if err != nil {
+		return
+	}
+	return apiWriteNote(w, http.StatusCreated, note.Id)
+}
+
+// PATCH /notes/ID
+func apiModifyNote(w http.ResponseWriter, r *http.Request, n Note) (err error) {
+	var req apiModifyRequest
+	r.Body = http.MaxBytesReader(w, r.Body, apiMaxBodySize)
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return apiBodyError(err)
+	}
+
+	if err = n.AddTags(req.AddTags); err != nil {
+		return apiError{http.StatusBadRequest, err}
+	}
+	n.RemoveTags(req.RemoveTags)
+
+	for k, v := range req.Attributes {
+		if slices.Contains(dateAttributes, k) {
+			err = n.SetDate(k, v)
+		} else {
+			err = n.SetAttribute(k, v)
+		}
+		if err != nil {
+			return apiError{http.StatusBadRequest, err}
+		}
+	}
+
+	if req.Title != nil {
+		if strings.TrimSpace(*req.Title) == "" {
+			return apiError{http.StatusBadRequest, errors.New("Title must not be empty")}
+		}
+		n.Title = *req.Title
+	}
+
+	if err = n.WriteData(); err != nil {
+		return
+	}
+	return apiWriteNote(w, http.StatusOK, n.Id)
+}
+
+// GET /notes/ID/content[?version=VERSION]
+func apiNoteContent(w http.ResponseWriter, r *http.Request, n Note) (err error) {
+	version := r.URL.Query().Get("version")
+	if version != "" && slices.Contains(n.Versions, version) == false {
+		return apiError{http.StatusNotFound, errors.New("Version not found: " + version)}
+	}
+	if version == "" {
+		version = n.LatestVersion()
+	}
+
+	content, err := n.Content(version)
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("X-Note-Version", version)
+	_, err = w.Write(content)
+	return
+}
+
+// POST /notes/ID/append and /notes/ID/prepend with the text as body
+func apiAppendNote(w http.ResponseWriter, r *http.Request, n Note, mode string) (err error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, apiMaxBodySize))
+	if err != nil {
+		return apiBodyError(err)
+	}
+
+	text := string(body)
+	if strings.TrimSpace(text) == "" {
+		return apiError{http.StatusBadRequest, errors.New("Missing text")}
+	}
+	if strings.HasSuffix(text, "\n") == false {
+		text += "\n"
+	}
+
+	err = noteAppendHandler(n, mode, text)
+	if err != nil {
+		return
+	}
+	return apiWriteNote(w, http.StatusOK, n.Id)
+}
+
+// GET /notes/ID/attachments/NAME
+func apiDownloadAttachment(w http.ResponseWriter, r *http.Request, n Note, name string) (err error) {
+	if slices.IndexFunc(n.Attachments, func(a Attachment) bool { return a.Filename == name }) == -1 {
+		return apiError{http.StatusNotFound, errors.New("Attachment not found: " + name)}
+	}
+
+	f, err := os.Open(filepath.Join(n.Path(), "attachments", name))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, info.ModTime(), f)
+	return
+}
+
+// PUT /notes/ID/attachments/NAME with the file as body
+func apiUploadAttachment(w http.ResponseWriter, r *http.Request, n Note, name string) (err error) {
+	if name != filepath.Base(name) || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return apiError{http.StatusBadRequest, errors.New("Invalid file name: " + name)}
+	}
+
+	dir, err := os.MkdirTemp(notemanager.TempDir, "upload")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, name)
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, notemanager.FilePermission)
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(f, http.MaxBytesReader(w, r.Body, apiMaxUploadSize))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apiError{http.StatusRequestEntityTooLarge, err}
+	}
+	if err != nil {
+		return
+	}
+
+	err = n.Attach(file, name)
+	if err != nil {
+		return apiError{http.StatusConflict, err}
+	}
+	return apiWriteNote(w, http.StatusCreated, n.Id)
+}
+
+// GET /search?q=REGEXP[&filter=TERMS][&case=true]
+func apiSearch(w http.ResponseWriter, r *http.Request) (err error) {
+	needle := r.URL.Query().Get("q")
+	if needle == "" {
+		return apiError{http.StatusBadRequest, errors.New("Missing query parameter q")}
+	}
+
+	filter, err := apiFilter(r)
+	if err != nil {
+		return
+	}
+
+	found, err := notes(filter)
+	if err != nil {
+		return
+	}
+
+	matches, err := searchNotes(found, needle, r.URL.Query().Get("case") == "true")
+	if err != nil {
+		return apiError{http.StatusBadRequest, err}
+	}
+
+	list := make([]apiSearchMatch, 0, len(matches))
+	for _, m := range matches {
+		list = append(list, apiSearchMatch{Id: m.Note.Id.String(), Title: m.Note.Title, Lines: m.Lines})
+	}
+	return writeJSON(w, http.StatusOK, list)
+}
+
+// reload note id and write it as response
+func apiWriteNote(w http.ResponseWriter, status int, id uuid.UUID) (err error) {
+	n, err := loadNote(id.String())
+	if err != nil {
+		return
+	}
+	return writeJSON(w, status, newAPINote(n))
+}
+
+func newAPINote(n Note) (a apiNote) {
+	a = apiNote{
+		Id:          n.Id.String(),
+		Title:       n.Title,
+		Alias:       n.Alias,
+		Tags:        n.Tags,
+		VirtualTags: n.VirtualTags,
+		Attributes:  n.Attributes,
+		Versions:    n.Versions,
+		Attachments: []apiAttachment{},
+		Pinned:      n.Pinned,
+		Priority:    n.Priority,
+		Created:     n.DateCreated,
+		Deleted:     apiTime(n.DateDeleted),
+		Archived:    apiTime(n.DateArchived),
+		Due:         apiTime(n.DateDue),
+		Review:      apiTime(n.DateReview),
+	}
+	if a.Tags == nil {
+		a.Tags = []string{}
+	}
+	if a.VirtualTags == nil {
+		a.VirtualTags = []string{}
+	}
+	if len(n.DateModified) > 0 {
+		a.Modified = apiTime(n.DateModified[len(n.DateModified)-1])
+	}
+	for _, f := range n.Attachments {
+		a.Attachments = append(a.Attachments, apiAttachment{Filename: f.Filename, Sha1: f.Sha1, Created: f.DateCreated})
+	}
+	return
+}
+
+// returns nil for unset times, which are omitted
+func apiTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) (err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// write error as JSON object, e.g. {"error": "Note not found: 1a2b3c4d"}
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var e apiError
+	if errors.As(err, &e) {
+		status = e.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+const testToken = "0123456789abcdef"
+
+// send request to the API with the test token
+func apiRequest(t *testing.T, h http.Handler, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// create note by the API and return it
+func apiTestNote(t *testing.T, h http.Handler, body string) (n apiNote) {
+	t.Helper()
+
+	w := apiRequest(t, h, http.MethodPost, "/notes", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /notes: %d %s", w.Code, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &n); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestAPIToken(t *testing.T) {
+	testDataDir(t)
+	h := newAPIServer(testToken)
+
+	for _, auth := range []string{"", "Bearer", "Bearer wrong", testToken, "Basic " + testToken} {
+		r := httptest.NewRequest(http.MethodGet, "/notes", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want %d", auth, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	if w := apiRequest(t, h, http.MethodGet, "/notes", ""); w.Code != http.StatusOK {
+		t.Errorf("valid token: status %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAPICreateNote(t *testing.T) {
+	testDataDir(t)
+	h := newAPIServer(testToken)
+
+	n := apiTestNote(t, h, `{"title": "Meeting", "tags": ["work"], "content": "Agenda"}`)
+	if n.Title != "Meeting" || slices.Equal(n.Tags, []string{"work"}) == false || len(n.Versions) != 1 {
+		t.Errorf("created note %+v", n)
+	}
+
+	w := apiRequest(t, h, http.MethodGet, "/notes/"+n.Id+"/content", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET content: %d %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "Agenda\n") == false {
+		t.Errorf("content %q does not contain the posted content", w.Body)
+	}
+	if v := w.Header().Get("X-Note-Version"); v != n.Versions[0] {
+		t.Errorf("X-Note-Version = %q, want %q", v, n.Versions[0])
+	}
+
+	// short ids select the note as well
+	if w := apiRequest(t, h, http.MethodGet, "/notes/"+n.Id[0:8], ""); w.Code != http.StatusOK {
+		t.Errorf("GET by short id: %d %s", w.Code, w.Body)
+	}
+
+	if w := apiRequest(t, h, http.MethodPost, "/notes", `{"title": "Bad", "tags": ["a b"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid tag: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAPIModifyTags(t *testing.T) {
+	testDataDir(t)
+	h := newAPIServer(testToken)
+
+	n := apiTestNote(t, h, `{"title": "Todo", "tags": ["work", "open"], "content": "- [ ] call"}`)
+
+	w := apiRequest(t, h, http.MethodPatch, "/notes/"+n.Id, `{"addTags": ["done"], "removeTags": ["open"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH: %d %s", w.Code, w.Body)
+	}
+	var modified apiNote
+	if err := json.Unmarshal(w.Body.Bytes(), &modified); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"done", "work"}
+	tags := slices.Clone(modified.Tags)
+	slices.Sort(tags)
+	if slices.Equal(tags, want) == false {
+		t.Errorf("tags = %v, want %v", modified.Tags, want)
+	}
+
+	// the change is written to the store
+	w = apiRequest(t, h, http.MethodGet, "/notes?filter=%2Bdone", "")
+	var list []apiNote
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Id != n.Id {
+		t.Errorf("GET /notes?filter=+done = %+v", list)
+	}
+}
+
+func TestAPIFilterError(t *testing.T) {
+	testDataDir(t)
+	h := newAPIServer(testToken)
+
+	for _, filter := range []string{
+		"created.after:never",
+		"abcdef12",
+		"work",
+		"'unterminated",
+	} {
+		w := apiRequest(t, h, http.MethodGet, "/notes?filter="+strings.ReplaceAll(filter, " ", "+"), "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("filter %q: status %d, want %d", filter, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	if w := apiRequest(t, h, http.MethodGet, "/notes/abcdef12", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown note: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestAPIErrorsDoNotExit(t *testing.T) {
+	testDataDir(t)
+	h := newAPIServer(testToken)
+
+	// unloadable note in strict mode
+	notemanager.Strict = true
+	if err := os.Mkdir(filepath.Join(notemanager.NoteDir, "broken"), notemanager.DirPermission); err != nil {
+		t.Fatal(err)
+	}
+	if w := apiRequest(t, h, http.MethodGet, "/notes", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("strict mode: status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	notemanager.Strict = false
+	if w := apiRequest(t, h, http.MethodGet, "/notes", ""); w.Code != http.StatusOK {
+		t.Errorf("unloadable note: status %d %s", w.Code, w.Body)
+	}
+
+	n := apiTestNote(t, h, `{"title": "Limit"}`)
+	large := `{"title": "` + strings.Repeat("x", apiMaxBodySize) + `"}`
+	for _, path := range []string{"/notes", "/notes/" + n.Id + "/append"} {
+		if w := apiRequest(t, h, http.MethodPost, path, large); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("POST %s: status %d, want %d", path, w.Code, http.StatusRequestEntityTooLarge)
+		}
+	}
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// one side of a synchronized note, i.e. the note directory and its data
+type syncSide struct {
+	path string
+	note Note
+	// modification time of the data file, any change of the note
+	// metadata rewrites the data file
+	mtime time.Time
+}
+
+// counters of a sync run
+type syncResult struct {
+	pushed    int
+	pulled    int
+	updated   int
+	conflicts int
+}
+
+// alias which refers to different notes locally and in the shared directory
+type syncAliasCollision struct {
+	alias  string
+	local  uuid.UUID
+	remote uuid.UUID
+}
+
+// CMD: note sync DIR
+func syncHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note sync", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteSync() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() != 1 {
+		helpNoteSync()
+	}
+
+	dir, err := parsePathOption(fs.Arg(0))
+	if err == nil {
+		err = syncDataDir(dir)
+	}
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// Merge the notes and aliases of the data directory and the shared
+// directory dir in both directions. The shared directory has the layout
+// of a data directory and is created if it is missing.
+func syncDataDir(dir string) (err error) {
+	if filepath.Clean(dir) == filepath.Clean(notemanager.DataDir) {
+		return errors.New("Cannot sync the data directory with itself")
+	}
+
+	remoteNoteDir := filepath.Join(dir, "notes")
+	remoteAliasesPath := filepath.Join(dir, "aliases")
+	if DirExists(remoteNoteDir) == false {
+		fmt.Println(`Creating directory ` + remoteNoteDir)
+		err = os.MkdirAll(remoteNoteDir, notemanager.DirPermission)
+		if err != nil {
+			return
+		}
+	}
+
+	lock, err := lockDir(dir)
+	if err != nil {
+		return
+	}
+	defer lock.Close()
+
+	remoteAliases, err := readAliases(remoteAliasesPath)
+	if err != nil && errors.Is(err, os.ErrNotExist) == false {
+		return
+	}
+
+	localAliases, err := readAliases(notemanager.AliasesPath)
+	if err != nil {
+		return
+	}
+
+	for _, c := range mergeAliases(localAliases, remoteAliases) {
+		fmt.Printf("Alias %s refers to %s locally and to %s in the shared directory, not merged\n", c.alias, c.local.String()[0:8], c.remote.String()[0:8])
+	}
+
+	err = localAliases.WriteFile(notemanager.AliasesPath)
+	if err != nil {
+		return
+	}
+	err = remoteAliases.WriteFile(remoteAliasesPath)
+	if err != nil {
+		return
+	}
+	aliases = localAliases
+
+	ids, err := syncNoteIds(notemanager.NoteDir, remoteNoteDir)
+	if err != nil {
+		return
+	}
+
+	var result syncResult
+	for _, id := range ids {
+		local := filepath.Join(notemanager.NoteDir, id)
+		remote := filepath.Join(remoteNoteDir, id)
+
+		switch {
+		case DirExists(remote) == false:
+			err = syncCopyNote(local, remote)
+			result.pushed++
+
+		case DirExists(local) == false:
+			err = syncCopyNote(remote, local)
+			result.pulled++
+
+		default:
+			var updated, conflict bool
+			updated, conflict, err = syncMergeNote(local, remote, localAliases, remoteAliases)
+			if updated {
+				result.updated++
+			}
+			if conflict {
+				result.conflicts++
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %s", id[0:8], err)
+		}
+	}
+
+	fmt.Printf("Pushed %d, pulled %d and updated %d notes, %d conflicts.\n", result.pushed, result.pulled, result.updated, result.conflicts)
+	if result.conflicts > 0 {
+		fmt.Println("List notes in conflict with: note +CONFLICT list")
+	}
+
+	return
+}
+
+// Add aliases missing on either side. Aliases which refer to different
+// notes on both sides are returned and kept as they are, as are aliases
+// of notes which have another alias on the other side.
+func mergeAliases(local NoteAliases, remote NoteAliases) (collisions []syncAliasCollision) {
+	// add alias of id to dst, which is the local aliases if toLocal is set
+	add := func(dst NoteAliases, alias string, id uuid.UUID, toLocal bool) {
+		if other, exists := dst.Get(alias); exists {
+			if other != id {
+				c := syncAliasCollision{alias: alias, local: id, remote: other}
+				if toLocal {
+					c.local, c.remote = other, id
+				}
+				collisions = append(collisions, c)
+			}
+			return
+		}
+		if len(dst.FindById(id)) > 0 {
+			return
+		}
+		dst[alias] = id
+	}
+
+	names := func(a NoteAliases) (ret []string) {
+		for alias := range a {
+			ret = append(ret, alias)
+		}
+		sort.Strings(ret)
+		return
+	}
+
+	localNames, remoteNames := names(local), names(remote)
+	for _, alias := range localNames {
+		add(remote, alias, local[alias], false)
+	}
+	for _, alias := range remoteNames {
+		if _, exists := local.Get(alias); exists == false {
+			add(local, alias, remote[alias], true)
+		}
+	}
+
+	return
+}
+
+// returns sorted ids of the notes of both note directories
+func syncNoteIds(dirs ...string) (ids []string, err error) {
+	for _, dir := range dirs {
+		var entries []os.DirEntry
+		entries, err = os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if _, err := uuid.Parse(e.Name()); err != nil || e.IsDir() == false {
+				continue
+			}
+			if slices.Contains(ids, e.Name()) == false {
+				ids = append(ids, e.Name())
+			}
+		}
+	}
+	sort.Strings(ids)
+	return
+}
+
+// Copy note directory src, which is missing on the other side, to dst
+func syncCopyNote(src string, dst string) (err error) {
+	manifest, err := copyDataDir(src, dst)
+	if err == nil {
+		err = manifest.Verify(dst)
+	}
+	if err != nil {
+		os.RemoveAll(dst)
+	}
+	return
+}
+
+// read data file of note directory path
+func readSyncSide(path string) (s syncSide, err error) {
+	s.path = path
+	file := filepath.Join(path, "data")
+
+	yml, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	err = yaml.Unmarshal(yml, &s.note)
+	if err != nil {
+		return
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	s.mtime = info.ModTime()
+	return
+}
+
+// Merge a note which exists on both sides. Versions and attachments
+// missing on either side are copied, the metadata is taken from the side
+// which changed last. If both sides have versions the other side lacks,
+// all versions are kept and the note is in conflict.
+func syncMergeNote(localPath string, remotePath string, localAliases NoteAliases, remoteAliases NoteAliases) (updated bool, conflict bool, err error) {
+	local, err := readSyncSide(localPath)
+	if err != nil {
+		return
+	}
+	remote, err := readSyncSide(remotePath)
+	if err != nil {
+		return
+	}
+
+	// metadata of the side changed last
+	merged := local.note
+	if remote.mtime.After(local.mtime) {
+		merged = remote.note
+	}
+
+	// versions of both sides and the new names of renamed versions,
+	// so no two versions are renamed to the same name
+	taken := Note{Versions: mergeVersions(local.note.Versions, remote.note.Versions, nil)}
+	localOnly, remoteOnly, renamed, err := syncFiles(local.path, remote.path, local.note.Versions, remote.note.Versions,
+		func(name string, path string) string {
+			t, err := time.Parse(notemanager.VersionTimeFormat, name)
+			if err != nil {
+				t = time.Now()
+			}
+			version := taken.newVersion(t)
+			taken.Versions = append(taken.Versions, version)
+			return version
+		})
+	if err != nil {
+		return
+	}
+
+	var renamedVersions []string
+	for _, r := range renamed {
+		renamedVersions = append(renamedVersions, r.to)
+	}
+	merged.Versions = mergeVersions(local.note.Versions, remote.note.Versions, renamedVersions)
+	merged.DateModified = mergeTimes(local.note.DateModified, remote.note.DateModified)
+
+	switch {
+	case len(localOnly) > 0 && len(remoteOnly) > 0, len(renamed) > 0:
+		// both sides have been edited, keep the heads of both in conflict
+		conflict = true
+		var heads []string
+		for _, v := range []string{lastVersion(localOnly), lastVersion(remoteOnly), lastVersion(renamedVersions)} {
+			if v != "" {
+				heads = append(heads, v)
+			}
+		}
+		merged.Conflicts = mergeVersions(local.note.Conflicts, remote.note.Conflicts, heads)
+	case len(localOnly) > 0:
+		merged.Conflicts = local.note.Conflicts
+	case len(remoteOnly) > 0:
+		merged.Conflicts = remote.note.Conflicts
+	}
+	if len(merged.Conflicts) > 0 {
+		conflict = true
+	}
+
+	merged.Attachments, err = syncAttachments(local, remote)
+	if err != nil {
+		return
+	}
+
+	for _, side := range []struct {
+		s       syncSide
+		aliases NoteAliases
+	}{{local, localAliases}, {remote, remoteAliases}} {
+		var changed bool
+		changed, err = writeSyncedNote(side.s, merged, side.aliases)
+		if err != nil {
+			return
+		}
+		updated = updated || changed
+	}
+
+	if conflict && updated {
+		fmt.Printf("%s: Conflict, versions %s\n", merged.ShortId(), strings.Join(merged.Conflicts, ", "))
+	} else if updated {
+		fmt.Printf("%s: Updated\n", merged.ShortId())
+	}
+
+	return
+}
+
+// file of a note renamed by sync, because both sides had a file of the
+// same name with different content
+type syncRename struct {
+	from string
+	to   string
+	// whether the renamed file came from the local side
+	local bool
+}
+
+// Copy files of both note directories, which are missing on the other
+// side. Of files with the same name and different content, the one with
+// the greater sha1 checksum is renamed by rename on both sides, so all
+// clients of a shared directory pick the same name. Returns the names
+// only present locally or remotely and the renamed files.
+func syncFiles(local string, remote string, localNames []string, remoteNames []string, rename func(name string, path string) string) (localOnly []string, remoteOnly []string, renamed []syncRename, err error) {
+	for _, name := range localNames {
+		if slices.Contains(remoteNames, name) {
+			continue
+		}
+		localOnly = append(localOnly, name)
+		err = syncCopyFile(filepath.Join(local, name), filepath.Join(remote, name))
+		if err != nil {
+			return
+		}
+	}
+
+	for _, name := range remoteNames {
+		if slices.Contains(localNames, name) == false {
+			remoteOnly = append(remoteOnly, name)
+			err = syncCopyFile(filepath.Join(remote, name), filepath.Join(local, name))
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		var localSum, remoteSum string
+		localSum, err = fileSha1(filepath.Join(local, name))
+		if err != nil {
+			return
+		}
+		remoteSum, err = fileSha1(filepath.Join(remote, name))
+		if err != nil {
+			return
+		}
+		if localSum == remoteSum {
+			continue
+		}
+
+		// same name, different content
+		keep, move := local, remote
+		if localSum > remoteSum {
+			keep, move = remote, local
+		}
+		r := syncRename{from: name, to: rename(name, filepath.Join(move, name)), local: move == local}
+		// never rename over an existing file of either side
+		for _, dir := range []string{move, keep} {
+			if _, err = os.Lstat(filepath.Join(dir, r.to)); err == nil {
+				return localOnly, remoteOnly, renamed, fmt.Errorf("%s: File exists, cannot rename %s", filepath.Join(dir, r.to), name)
+			}
+		}
+		err = os.Rename(filepath.Join(move, name), filepath.Join(move, r.to))
+		if err != nil {
+			return
+		}
+		err = syncCopyFile(filepath.Join(keep, name), filepath.Join(move, name))
+		if err != nil {
+			return
+		}
+		err = syncCopyFile(filepath.Join(move, r.to), filepath.Join(keep, r.to))
+		if err != nil {
+			return
+		}
+		renamed = append(renamed, r)
+	}
+
+	return
+}
+
+// Copy file src to the missing file dst, keeping mode and modification
+// time. A file dst with the same content, e.g. copied by another client
+// of the shared directory, is kept.
+func syncCopyFile(src string, dst string) (err error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return
+	}
+	if _, err = os.Stat(dst); err == nil {
+		srcSum, _ := fileSha1(src)
+		dstSum, _ := fileSha1(dst)
+		if srcSum == "" || srcSum != dstSum {
+			return fmt.Errorf("%s: File exists with different content", dst)
+		}
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(dst), notemanager.DirPermission)
+	if err != nil {
+		return
+	}
+	return copyFile(src, dst, info)
+}
+
+// Copy attachments missing on either side and return the union of the
+// attachments of both sides. Of two different attachments with the same
+// name, one is renamed by its checksum, e.g. file-1a2b3c4d.pdf
+func syncAttachments(local syncSide, remote syncSide) (attachments []Attachment, err error) {
+	names := func(n Note) (ret []string) {
+		for _, a := range n.Attachments {
+			ret = append(ret, a.Filename)
+		}
+		return
+	}
+
+	_, _, renamed, err := syncFiles(
+		filepath.Join(local.path, "attachments"),
+		filepath.Join(remote.path, "attachments"),
+		names(local.note), names(remote.note),
+		func(name string, path string) string {
+			sum, _ := fileSha1(path)
+			if len(sum) > 8 {
+				sum = sum[0:8]
+			}
+			ext := filepath.Ext(name)
+			return strings.TrimSuffix(name, ext) + "-" + sum + ext
+		})
+	if err != nil {
+		return
+	}
+
+	for _, side := range []syncSide{local, remote} {
+		for _, a := range side.note.Attachments {
+			for _, r := range renamed {
+				if r.from == a.Filename && r.local == (side.path == local.path) {
+					fmt.Printf("%s: Attachment %s differs on both sides, renamed one to %s\n", side.note.ShortId(), r.from, r.to)
+					a.Filename = r.to
+				}
+			}
+			if slices.IndexFunc(attachments, func(x Attachment) bool { return x.Filename == a.Filename }) == -1 {
+				attachments = append(attachments, a)
+			}
+		}
+	}
+	sort.SliceStable(attachments, func(i, j int) bool {
+		return attachments[i].DateCreated.Before(attachments[j].DateCreated)
+	})
+
+	return
+}
+
+// Write merged note data to side, if it differs from the data of the
+// side. The alias of the note is set to an alias which refers to the note
+// on this side, as the aliases of both sides may differ. Returns whether
+// the data file has been written.
+func writeSyncedNote(side syncSide, merged Note, a NoteAliases) (changed bool, err error) {
+	if id, exists := a.Get(merged.Alias); exists == false || id != merged.Id {
+		merged.Alias = ""
+		names := a.FindById(merged.Id)
+		sort.Strings(names)
+		if len(names) > 0 {
+			merged.Alias = names[0]
+		}
+	}
+
+	yml := merged.Yaml()
+	if string(yml) == string(side.note.Yaml()) {
+		return
+	}
+
+	err = writeFileAtomic(filepath.Join(side.path, "data"), yml, notemanager.FilePermission)
+	changed = err == nil
+	return
+}
+
+// returns union of version lists a, b and c in chronological order
+func mergeVersions(a []string, b []string, c []string) (versions []string) {
+	for _, list := range [][]string{a, b, c} {
+		for _, v := range list {
+			if slices.Contains(versions, v) == false {
+				versions = append(versions, v)
+			}
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		ti, erri := time.Parse(notemanager.VersionTimeFormat, versions[i])
+		tj, errj := time.Parse(notemanager.VersionTimeFormat, versions[j])
+		if erri != nil || errj != nil {
+			return versions[i] < versions[j]
+		}
+		return ti.Before(tj)
+	})
+	return
+}
+
+// returns union of time lists a and b in chronological order
+func mergeTimes(a []time.Time, b []time.Time) (times []time.Time) {
+	for _, t := range append(append([]time.Time{}, a...), b...) {
+		if slices.IndexFunc(times, func(x time.Time) bool { return x.Equal(t) }) == -1 {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return
+}
+
+// returns latest of versions or an empty string
+func lastVersion(versions []string) string {
+	versions = mergeVersions(versions, nil, nil)
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
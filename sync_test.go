@@ -0,0 +1,175 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// use a new data directory in the temp dir of the test
+func testDataDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	notemanager = defaultConfig()
+	notemanager.DataDir = dir
+	notemanager.AliasesPath = filepath.Join(dir, "aliases")
+	notemanager.TemplateDir = filepath.Join(dir, "templates")
+	notemanager.TempDir = filepath.Join(dir, "tmp")
+	notemanager.NoteDir = filepath.Join(dir, "notes")
+
+	if err := initDataDir(); err != nil {
+		t.Fatal(err)
+	}
+	aliases = make(NoteAliases)
+
+	return dir
+}
+
+// write data file and versions of note n to note directory noteDir,
+// contents are the contents of the versions
+func testWriteNote(t *testing.T, noteDir string, n Note, contents ...string) {
+	t.Helper()
+
+	dir := filepath.Join(noteDir, n.Id.String())
+	if err := os.MkdirAll(dir, notemanager.DirPermission); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range n.Versions {
+		if err := os.WriteFile(filepath.Join(dir, v), []byte(contents[i]), notemanager.FilePermission); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), n.Yaml(), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncConflict(t *testing.T) {
+	testDataDir(t)
+	shared := t.TempDir()
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Sync",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "base\n")
+
+	if err := syncDataDir(shared); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(shared, "notes", n.Id.String(), "20260101-100000")); err != nil {
+		t.Fatalf("note not pushed: %s", err)
+	}
+
+	// both sides edit the note before the next sync
+	local, remote := n, n
+	local.Versions = []string{"20260101-100000", "20260102-100000"}
+	remote.Versions = []string{"20260101-100000", "20260103-100000"}
+	testWriteNote(t, notemanager.NoteDir, local, "base\n", "local\n")
+	testWriteNote(t, filepath.Join(shared, "notes"), remote, "base\n", "remote\n")
+
+	if err := syncDataDir(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := loadNote(n.Id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(merged.VirtualTags, "CONFLICT") == false {
+		t.Errorf("virtual tags = %v, want CONFLICT", merged.VirtualTags)
+	}
+	want := []string{"20260101-100000", "20260102-100000", "20260103-100000"}
+	if slices.Equal(merged.Versions, want) == false {
+		t.Errorf("versions = %v, want %v", merged.Versions, want)
+	}
+	if slices.Equal(merged.Conflicts, want[1:]) == false {
+		t.Errorf("conflicts = %v, want %v", merged.Conflicts, want[1:])
+	}
+
+	// the shared directory has the same state
+	side, err := readSyncSide(filepath.Join(shared, "notes", n.Id.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(side.note.Versions, want) == false || slices.Equal(side.note.Conflicts, want[1:]) == false {
+		t.Errorf("shared note has versions %v and conflicts %v", side.note.Versions, side.note.Conflicts)
+	}
+	content, err := os.ReadFile(filepath.Join(side.path, "20260102-100000"))
+	if err != nil || string(content) != "local\n" {
+		t.Errorf("shared version 20260102-100000 = %q, %v", content, err)
+	}
+}
+
+func TestMergeAliasesCollision(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	local := NoteAliases{"meeting": a, "todo": b}
+	remote := NoteAliases{"meeting": c, "ideas": c}
+
+	collisions := mergeAliases(local, remote)
+
+	want := []syncAliasCollision{{alias: "meeting", local: a, remote: c}}
+	if slices.Equal(collisions, want) == false {
+		t.Errorf("collisions = %v, want %v", collisions, want)
+	}
+	if local["meeting"] != a || remote["meeting"] != c {
+		t.Errorf("colliding alias was merged: local %s, remote %s", local["meeting"], remote["meeting"])
+	}
+	if remote["todo"] != b {
+		t.Errorf("alias todo not pushed: %v", remote)
+	}
+	// c has no alias locally, as meeting refers to another note
+	if _, exists := local["ideas"]; exists == false {
+		t.Errorf("alias ideas not pulled: %v", local)
+	}
+}
+
+func TestSyncAdjacentVersionCollisions(t *testing.T) {
+	testDataDir(t)
+	shared := t.TempDir()
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       "Sync",
+		DateCreated: time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Versions:    []string{"20260101-100000", "20260102-100000", "20260102-100001"},
+	}
+	testWriteNote(t, notemanager.NoteDir, n, "base\n", "local 1\n", "local 2\n")
+	testWriteNote(t, filepath.Join(shared, "notes"), n, "base\n", "remote 1\n", "remote 2\n")
+
+	if err := syncDataDir(shared); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := loadNote(n.Id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Versions) != 5 {
+		t.Fatalf("versions = %v, want 5 versions", merged.Versions)
+	}
+
+	// every content is kept on both sides
+	for _, dir := range []string{merged.Path(), filepath.Join(shared, "notes", n.Id.String())} {
+		var contents []string
+		for _, v := range merged.Versions {
+			content, err := os.ReadFile(filepath.Join(dir, v))
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents = append(contents, string(content))
+		}
+		for _, want := range []string{"base\n", "local 1\n", "local 2\n", "remote 1\n", "remote 2\n"} {
+			if slices.Contains(contents, want) == false {
+				t.Errorf("%s: content %q lost, versions have %q", dir, want, contents)
+			}
+		}
+	}
+}
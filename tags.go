@@ -0,0 +1,303 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// node of the tag tree. Levels of hierarchical tags are separated by dots.
+type TagNode struct {
+	Name     string
+	Path     string
+	Notes    []TaggedNote // notes tagged with exactly this tag
+	Children map[string]*TagNode
+	// ids of all notes tagged with this tag or one of its sub tags
+	ids map[string]bool
+}
+
+type TaggedNote struct {
+	Id          string
+	Title       string
+	DateCreated time.Time
+}
+
+// add note to tag node of path, creating all intermediate nodes
+func (t *TagNode) add(path string, n Note) {
+	node := t
+	node.ids[n.Id.String()] = true
+	for _, name := range strings.Split(path, ".") {
+		child, ok := node.Children[name]
+		if ok == false {
+			p := name
+			if node.Path != "" {
+				p = node.Path + "." + name
+			}
+			child = &TagNode{Name: name, Path: p, Children: make(map[string]*TagNode), ids: make(map[string]bool)}
+			node.Children[name] = child
+		}
+		child.ids[n.Id.String()] = true
+		node = child
+	}
+	node.Notes = append(node.Notes, TaggedNote{n.ShortId(), n.Title, n.DateCreated})
+}
+
+// number of notes tagged with this tag or one of its sub tags
+func (t *TagNode) Count() int {
+	return len(t.ids)
+}
+
+// returns child nodes sorted by order count or name
+func (t *TagNode) sortedChildren(order string) []*TagNode {
+	ret := make([]*TagNode, 0, len(t.Children))
+	for _, c := range t.Children {
+		ret = append(ret, c)
+	}
+
+	// sort alphabetically by tag first
+	// or results of multiple calls vary slightly.
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+
+	switch order {
+	case "count":
+		sort.SliceStable(ret, func(i, j int) bool {
+			return ret[i].Count() > ret[j].Count()
+		})
+	}
+
+	return ret
+}
+
+// build tree of tags of notes
+func tagTree(notes []Note) *TagNode {
+	root := &TagNode{Children: make(map[string]*TagNode), ids: make(map[string]bool)}
+	for _, n := range notes {
+		for _, tag := range n.Tags {
+			root.add(tag, n)
+		}
+	}
+	return root
+}
+
+// CMD: note [FILTER] tags [OPTIONS] [rename OLD NEW|merge A B|delete TAG]
+func tagsHandler(filter NoteFilter, args []string) (err error) {
+	var optHelp bool
+	var optOrder string
+	var optFull bool
+	var optFlat bool
+	fs := flag.NewFlagSet("note tags", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteTags() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.BoolVar(&optFull, "f", false, "Display notes along with tags")
+	fs.BoolVar(&optFull, "full", false, "Display notes along with tags")
+	fs.BoolVar(&optFlat, "flat", false, "Display flat list of tags instead of a tree")
+	fs.StringVar(&optOrder, "o", "count", "Ordering of tags. OPTIONS=count|name")
+	fs.StringVar(&optOrder, "order", "count", "Ordering of tags. OPTIONS=count|name")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp {
+		helpNoteTags()
+	}
+
+	args = fs.Args()
+	if len(args) > 0 {
+		switch args[0] {
+		case "rename", "merge":
+			if len(args) != 3 {
+				helpNoteTags()
+			}
+			err = renameTag(filter, args[1], args[2], args[0] == "merge")
+
+		case "delete":
+			if len(args) != 2 {
+				helpNoteTags()
+			}
+			err = deleteTag(filter, args[1])
+
+		default:
+			helpNoteTags()
+		}
+
+		if err != nil {
+			Exit(err.Error())
+		}
+		return
+	}
+
+	notes, err := notes(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	root := tagTree(notes)
+	if optFlat {
+		printTagsFlat(root, optOrder, optFull)
+	} else {
+		printTagTree(root, optOrder, optFull, 0)
+	}
+
+	return
+}
+
+func printTaggedNotes(notes []TaggedNote, indent string) {
+	for _, t := range notes {
+		fmt.Printf("%s- %s: %s (%s)\n", indent, t.Id, t.Title, t.DateCreated.Format(notemanager.OutputTimeFormatShort))
+	}
+}
+
+// display tags indented by level. Counts include sub tags.
+func printTagTree(node *TagNode, order string, full bool, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, c := range node.sortedChildren(order) {
+		fmt.Printf("%s%s (%d)\n", indent, c.Name, c.Count())
+		if full {
+			printTaggedNotes(c.Notes, indent+"  ")
+		}
+		printTagTree(c, order, full, depth+1)
+	}
+}
+
+// display full tag names, one per line. Counts exclude sub tags.
+func printTagsFlat(root *TagNode, order string, full bool) {
+	var nodes []*TagNode
+	var walk func(n *TagNode)
+	walk = func(n *TagNode) {
+		for _, c := range n.Children {
+			if len(c.Notes) > 0 {
+				nodes = append(nodes, c)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Path < nodes[j].Path
+	})
+
+	switch order {
+	case "count":
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return len(nodes[i].Notes) > len(nodes[j].Notes)
+		})
+	}
+
+	for _, n := range nodes {
+		fmt.Printf("%s (%d)\n", n.Path, len(n.Notes))
+		if full {
+			printTaggedNotes(n.Notes, "  ")
+			fmt.Println()
+		}
+	}
+}
+
+// returns tag and error, if tag is invalid
+func parseTag(tag string) (string, error) {
+	tag = normalizeTag(tag)
+	if reTag.MatchString(tag) == false {
+		return tag, fmt.Errorf("Invalid tag: %s", tag)
+	}
+	return tag, nil
+}
+
+// Rename tag from to tag to in all notes matching filter, including
+// deleted notes. Sub tags are renamed as well, e.g. renaming work to job
+// changes work.clientA to job.clientA. Unless merge is set, tag to must
+// not exist yet.
+func renameTag(filter NoteFilter, from string, to string, merge bool) (err error) {
+	if from, err = parseTag(from); err != nil {
+		return
+	}
+	if to, err = parseTag(to); err != nil {
+		return
+	}
+	if from == to {
+		return errors.New("Tags are identical")
+	}
+	// sub tags of from would be renamed over and over
+	if tagMatches(to, from) {
+		return fmt.Errorf("Tag %s cannot be renamed to its sub tag %s", from, to)
+	}
+
+	return rewriteTags(filter, func(n Note) (tags []string, err error) {
+		for _, t := range n.Tags {
+			switch {
+			case tagMatches(t, from):
+				t = to + strings.TrimPrefix(t, from)
+
+			case merge == false && tagMatches(t, to):
+				return nil, fmt.Errorf("Tag %s already exists, use 'note tags merge %s %s' instead", to, from, to)
+			}
+
+			if slices.Contains(tags, t) == false {
+				tags = append(tags, t)
+			}
+		}
+		return
+	})
+}
+
+// Remove tag and its sub tags from all notes matching filter
+func deleteTag(filter NoteFilter, tag string) (err error) {
+	if tag, err = parseTag(tag); err != nil {
+		return
+	}
+
+	return rewriteTags(filter, func(n Note) (tags []string, err error) {
+		for _, t := range n.Tags {
+			if tagMatches(t, tag) == false {
+				tags = append(tags, t)
+			}
+		}
+		return
+	})
+}
+
+// Replace tags of all notes matching filter with the result of fn.
+// All notes are checked before any note is written.
+func rewriteTags(filter NoteFilter, fn func(n Note) ([]string, error)) (err error) {
+	unlock, err := lockDataDir()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	filter.IncludeDeleted = true
+
+	all, err := notes(filter)
+	if err != nil {
+		return
+	}
+
+	var changed []Note
+	for _, n := range all {
+		tags, err := fn(n)
+		if err != nil {
+			return err
+		}
+		if slices.Equal(tags, n.Tags) {
+			continue
+		}
+		n.Tags = tags
+		changed = append(changed, n)
+	}
+
+	for _, n := range changed {
+		n.WriteData()
+		fmt.Printf("%s: %s\n", n.ShortId(), strings.Join(n.Tags, ", "))
+	}
+	fmt.Printf("%d notes changed.\n", len(changed))
+
+	return
+}
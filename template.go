@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// maximum depth of nested template includes
+const templateIncludeDepth = 10
+
+// File extension of templates with the syntax of text/template. Files
+// without it are plain text templates of previous versions, in which
+// only the {{ nm.* }} placeholders are replaced.
+const templateExtension = ".tmpl"
+
+// Note template from TemplateDir. A template may start with a YAML
+// front matter enclosed by --- lines, which sets defaults of new notes.
+type NoteTemplate struct {
+	Name        string   `yaml:"-"`
+	Description string   `yaml:"description,omitempty"`
+	Title       string   `yaml:"title,omitempty"`
+	Alias       string   `yaml:"alias,omitempty"`
+	Tags        []string `yaml:"tags,omitempty"`
+	Body        string   `yaml:"-"`
+	// plain text template without front matter
+	Plain bool `yaml:"-"`
+}
+
+// returns path of template file. A template with the template extension
+// is preferred over a plain text template of the same name. New
+// templates have the template extension.
+func templatePath(name string) string {
+	path := filepath.Clean(notemanager.TemplateDir + "/" + name)
+	if _, err := os.Stat(path + templateExtension); err != nil {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return path + templateExtension
+}
+
+// Load template from TemplateDir
+func loadTemplate(name string) (t NoteTemplate, err error) {
+	path := templatePath(name)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if strings.HasSuffix(path, templateExtension) == false {
+		return NoteTemplate{Name: name, Body: string(src), Plain: true}, nil
+	}
+	return parseTemplate(name, src)
+}
+
+// Split template source into front matter and body
+func parseTemplate(name string, src []byte) (t NoteTemplate, err error) {
+	t.Name = name
+	s := strings.ReplaceAll(string(src), "\r\n", "\n")
+
+	if strings.HasPrefix(s, "---\n") {
+		end := strings.Index(s[4:], "\n---")
+		if end == -1 {
+			err = fmt.Errorf("Template %s: Front matter is not terminated by ---", name)
+			return
+		}
+
+		err = yaml.Unmarshal([]byte(s[4:4+end+1]), &t)
+		if err != nil {
+			err = fmt.Errorf("Template %s: Invalid front matter: %s", name, err)
+			return
+		}
+		t.Name = name
+
+		s = s[4+end+4:]
+		s = strings.TrimPrefix(s, "\n")
+	}
+
+	t.Body = s
+	return
+}
+
+// Render template body with data of note n. content is the note content
+// supplied on the command line, which is available as {{ .Content }}.
+func (t NoteTemplate) Render(n Note, content []byte) (out []byte, err error) {
+	if t.Plain {
+		return renderPlainTemplate(t.Body, templateData(n, content)), nil
+	}
+	return renderTemplate(t.Name, t.Body, templateData(n, content), 0)
+}
+
+// returns true if the template body uses the supplied content
+func (t NoteTemplate) UsesContent() bool {
+	if t.Plain {
+		return strings.Contains(t.Body, "{{ nm.content }}")
+	}
+	return strings.Contains(t.Body, "nm.content") || strings.Contains(t.Body, ".Content")
+}
+
+// Apply front matter of template to note n. Tags are added, title and
+// alias patterns are rendered with the data of the note. The title
+// supplied by the user is available as {{ .Note.Title }}.
+func (t NoteTemplate) Apply(n *Note) (err error) {
+	for _, tag := range t.Tags {
+		err = n.AddTag(tag)
+		if err != nil {
+			return
+		}
+	}
+
+	if t.Title != "" {
+		var title []byte
+		title, err = renderTemplate(t.Name+":title", t.Title, templateData(*n, nil), 0)
+		if err != nil {
+			return
+		}
+		n.Title = strings.TrimSpace(string(title))
+	}
+
+	if t.Alias != "" {
+		var alias []byte
+		alias, err = renderTemplate(t.Name+":alias", t.Alias, templateData(*n, nil), 0)
+		if err != nil {
+			return
+		}
+		n.Alias = strings.TrimSpace(string(alias))
+	}
+
+	return
+}
+
+// Header of read and print, if the output template does not exist
+const defaultOutputTemplate = `+
++ Title:       {{ .Note.Title }}
++ Date:        {{ date .Note.DateCreated }}
+{{- if not .Modified.IsZero }}
++ Modified:    {{ date .Modified }}
+{{- end }}
+{{- if .Note.Alias }}
++ Alias:       {{ .Note.Alias }}
+{{- end }}
++
++ Tags:        {{ join .Note.Tags ", " }}
++ Virtual:     {{ join .Note.VirtualTags ", " }}
++ Attachments: {{ len .Note.Attachments }}
++ Version:     {{ .Version }}
++
+
+
+`
+
+// Render header of version of note n with the output template
+func (n Note) Header(version string) (out []byte, err error) {
+	var plain bool
+	src := defaultOutputTemplate
+	t, err := loadTemplate(notemanager.OutputTemplate)
+	switch {
+	case err == nil:
+		src = t.Body
+		plain = t.Plain
+
+	case errors.Is(err, os.ErrNotExist):
+		err = nil
+
+	default:
+		return
+	}
+
+	data := templateData(n, nil)
+	data["Version"] = version
+	data["Modified"] = time.Time{}
+	if len(n.DateModified) > 0 {
+		data["Modified"] = n.DateModified[len(n.DateModified)-1]
+	}
+
+	if plain {
+		return renderPlainTemplate(src, data), nil
+	}
+	return renderTemplate(notemanager.OutputTemplate, src, data, 0)
+}
+
+// Replace the placeholders of plain text templates, e.g. {{ nm.id }},
+// with the template data. Anything else is kept as it is.
+func renderPlainTemplate(src string, data map[string]interface{}) []byte {
+	nm, _ := data["nm"].(map[string]interface{})
+	created, _ := nm["created"].(map[string]string)
+	value := func(v interface{}) string {
+		s, _ := v.(string)
+		return s
+	}
+
+	return []byte(strings.NewReplacer(
+		"{{ nm.id }}", value(nm["id"]),
+		"{{ nm.created.date }}", created["date"],
+		"{{ nm.created.time }}", created["time"],
+		"{{ nm.created.offset }}", created["offset"],
+		"{{ nm.title }}", value(nm["title"]),
+		"{{ nm.tags }}", value(nm["tags"]),
+		"{{ nm.content }}", value(nm["content"]),
+	).Replace(src))
+}
+
+// Placeholders of the old template syntax, e.g. {{ nm.id }}, are
+// rewritten to access the nm map of the template data.
+var reLegacyPlaceholder = regexp.MustCompile(`\{\{(-?)(\s*)nm\.`)
+
+func renderTemplate(name string, src string, data map[string]interface{}, depth int) (out []byte, err error) {
+	if depth > templateIncludeDepth {
+		err = fmt.Errorf("Template %s: Too many nested includes", name)
+		return
+	}
+
+	tpl, err := compileTemplate(name, src, templateFuncs(data, depth))
+	if err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, data)
+	out = buf.Bytes()
+	return
+}
+
+// Parse template source. Placeholders of the old syntax are rewritten.
+func compileTemplate(name string, src string, funcs template.FuncMap) (*template.Template, error) {
+	src = reLegacyPlaceholder.ReplaceAllString(src, "{{$1$2.nm.")
+
+	return template.New(name).
+		Option("missingkey=zero").
+		Funcs(funcs).
+		Parse(src)
+}
+
+// Check syntax of template body, title and alias
+func (t NoteTemplate) Validate() (err error) {
+	if t.Plain {
+		return
+	}
+
+	funcs := templateFuncs(nil, 0)
+	for _, src := range []string{t.Body, t.Title, t.Alias} {
+		_, err = compileTemplate(t.Name, src, funcs)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Returns data available in templates
+func templateData(n Note, content []byte) map[string]interface{} {
+	env := make(map[string]string)
+	for _, e := range os.Environ() {
+		k, v, _ := strings.Cut(e, "=")
+		env[k] = v
+	}
+
+	created := n.DateCreated.UTC()
+
+	// the API token is no business of templates
+	config := notemanager
+	config.ServeToken = ""
+
+	return map[string]interface{}{
+		"Note":    n,
+		"Config":  config,
+		"Env":     env,
+		"Content": string(content),
+		// placeholders of the old template syntax
+		"nm": map[string]interface{}{
+			"id":    n.Id.String(),
+			"title": n.Title,
+			"tags":  strings.Join(n.Tags, ","),
+			"created": map[string]string{
+				"date":   created.Format("2006-01-02"),
+				"time":   created.Format("15:04"),
+				"offset": created.Format("-07:00"),
+			},
+			"content": string(content),
+		},
+	}
+}
+
+// answers of prompts, so every prompt is asked only once
+var templatePrompts = make(map[string]string)
+
+// stdin reader shared by all prompts
+var templatePromptReader *bufio.Reader
+
+// Returns functions available in templates
+func templateFuncs(data map[string]interface{}, depth int) template.FuncMap {
+	return template.FuncMap{
+		"now": time.Now,
+		"addDays": func(days int, t time.Time) time.Time {
+			return t.AddDate(0, 0, days)
+		},
+		"format": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		// time in local time zone and the long output format
+		"date": func(t time.Time) string {
+			return t.Local().Format(notemanager.OutputTimeFormatLong)
+		},
+		"join": strings.Join,
+		"env":  os.Getenv,
+		// {{ prompt "Label" ["Default"] }}
+		"prompt": func(label string, def ...string) (answer string, err error) {
+			if len(def) > 1 {
+				err = errors.New("prompt: Too many arguments")
+				return
+			}
+			if len(def) == 1 {
+				answer = def[0]
+			}
+
+			if a, ok := templatePrompts[label]; ok {
+				return a, nil
+			}
+
+			// do not block scripts
+			if stdinIsTerminal() == false {
+				templatePrompts[label] = answer
+				return
+			}
+
+			if templatePromptReader == nil {
+				templatePromptReader = bufio.NewReader(os.Stdin)
+			}
+			if answer != "" {
+				fmt.Printf("%s [%s]: ", label, answer)
+			} else {
+				fmt.Printf("%s: ", label)
+			}
+			input, _ := templatePromptReader.ReadString('\n')
+			if input = strings.TrimSpace(input); input != "" {
+				answer = input
+			}
+
+			templatePrompts[label] = answer
+			return
+		},
+		// {{ include "NAME" }}
+		"include": func(name string) (string, error) {
+			t, err := loadTemplate(name)
+			if err != nil {
+				return "", err
+			}
+			if t.Plain {
+				return string(renderPlainTemplate(t.Body, data)), nil
+			}
+			out, err := renderTemplate(name, t.Body, data, depth+1)
+			return string(out), err
+		},
+	}
+}
+
+// template names must be valid file names and must not be hidden
+var reTemplateName = regexp.MustCompile(`^[\pL0-9_\-][\pL0-9_\-.]*$`)
+
+// CMD: note template [list|add|edit|show|delete|rename|render] [NAME]
+func templateHandler(args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note template", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteTemplate() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp {
+		helpNoteTemplate()
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	action, rargs := args[0], args[1:]
+	if action == "list" {
+		listTemplates(notemanager.TemplateDir)
+		return
+	}
+
+	if len(rargs) == 0 {
+		helpNoteTemplate()
+	}
+
+	name := rargs[0]
+	if reTemplateName.MatchString(name) == false {
+		Exit("Invalid template name: " + name)
+	}
+
+	switch action {
+	case "add":
+		err = templateAddHandler(name)
+
+	case "edit":
+		err = templateEditHandler(name)
+
+	case "show":
+		var src []byte
+		src, err = os.ReadFile(templatePath(name))
+		if err == nil {
+			fmt.Printf("%s", src)
+		}
+
+	case "delete":
+		if _, err = os.Stat(templatePath(name)); err != nil {
+			break
+		}
+		if askYesNo(fmt.Sprintf("Delete template %s?", name)) {
+			var unlock func()
+			unlock, err = lockDataDir()
+			if err != nil {
+				break
+			}
+			defer unlock()
+
+			err = os.Remove(templatePath(name))
+			if err == nil {
+				fmt.Printf("Template %s deleted.\n", name)
+			}
+		}
+
+	case "rename":
+		if len(rargs) != 2 {
+			helpNoteTemplate()
+		}
+		err = templateRenameHandler(name, rargs[1])
+
+	case "render":
+		err = templateRenderHandler(name, rargs[1:])
+
+	default:
+		helpNoteTemplate()
+	}
+
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// Create template NAME and open it in the editor
+func templateAddHandler(name string) (err error) {
+	if _, err = os.Stat(templatePath(name)); err == nil {
+		return errors.New("Template already exists: " + name)
+	}
+
+	skeleton := `---
+description: ""
+tags: []
+---
+# {{ .Note.Title }}
+
+`
+	if name == notemanager.OutputTemplate {
+		skeleton = defaultOutputTemplate
+	}
+	return editTemplate(name, []byte(skeleton))
+}
+
+// Open template NAME in the editor
+func templateEditHandler(name string) (err error) {
+	src, err := os.ReadFile(templatePath(name))
+	if err != nil {
+		return
+	}
+
+	return editTemplate(name, src)
+}
+
+// Edit template source src in the editor and save it as template NAME.
+// The template is only saved, if it is valid.
+func editTemplate(name string, src []byte) (err error) {
+	tmpFile := filepath.Clean(fmt.Sprintf("%s/template.%s.%d%s", notemanager.TempDir, name, os.Getpid(), notemanager.TempFileExtension))
+	err = os.WriteFile(tmpFile, src, notemanager.FilePermission)
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmpFile)
+
+	path := templatePath(name)
+	plain := strings.HasSuffix(path, templateExtension) == false
+	for {
+		err = runEditor(tmpFile, 0)
+		if err != nil {
+			return
+		}
+
+		src, err = os.ReadFile(tmpFile)
+		if err != nil {
+			return
+		}
+
+		if plain {
+			break
+		}
+		var t NoteTemplate
+		t, err = parseTemplate(name, src)
+		if err == nil {
+			err = t.Validate()
+		}
+		if err == nil {
+			break
+		}
+
+		if askYesNo(fmt.Sprintf("Invalid template: %s\nEdit again?", err)) == false {
+			return errors.New("Template not saved")
+		}
+	}
+
+	unlock, err := lockDataDir()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	err = writeFileAtomic(path, src, notemanager.FilePermission)
+	if err == nil {
+		fmt.Printf("Template %s saved.\n", name)
+	}
+	return
+}
+
+// Rename template
+func templateRenameHandler(name string, newName string) (err error) {
+	if reTemplateName.MatchString(newName) == false {
+		return errors.New("Invalid template name: " + newName)
+	}
+
+	unlock, err := lockDataDir()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	path := templatePath(name)
+	if _, err = os.Stat(path); err != nil {
+		return
+	}
+
+	if _, err = os.Stat(templatePath(newName)); err == nil {
+		return errors.New("Template already exists: " + newName)
+	}
+
+	// plain text templates stay plain text
+	newPath := filepath.Join(notemanager.TemplateDir, newName)
+	if strings.HasSuffix(path, templateExtension) {
+		newPath += templateExtension
+	}
+	err = os.Rename(path, newPath)
+	if err == nil {
+		fmt.Printf("Template %s renamed to %s.\n", name, newName)
+	}
+	return
+}
+
+// Display template rendered for a note with title args
+func templateRenderHandler(name string, args []string) (err error) {
+	t, err := loadTemplate(name)
+	if err != nil {
+		return
+	}
+
+	n := Note{
+		Id:          uuid.New(),
+		Title:       strings.Join(args, " "),
+		DateCreated: time.Now().UTC(),
+	}
+	if n.Title == "" {
+		n.Title = "Undefined"
+	}
+
+	err = t.Apply(&n)
+	if err != nil {
+		return
+	}
+
+	out, err := t.Render(n, nil)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("+\n+ Title:       %s\n+ Tags:        %s\n+ Alias:       %s\n+\n\n%s", n.Title, strings.Join(n.Tags, ", "), n.Alias, out)
+	return
+}
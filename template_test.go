@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// write template file name to the template dir
+func testWriteTemplate(t *testing.T, name string, src string) {
+	t.Helper()
+
+	if err := os.MkdirAll(notemanager.TemplateDir, notemanager.DirPermission); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(notemanager.TemplateDir, name), []byte(src), notemanager.FilePermission); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlainTemplate(t *testing.T) {
+	testDataDir(t)
+
+	// a rule and braces of other tools are kept
+	testWriteTemplate(t, "plain", "---\n# {{ nm.title }}\n{{ .Values.name }} {{ nm.tags }}\n{{ nm.content }}\n")
+	tpl, err := loadTemplate("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Plain == false || tpl.UsesContent() == false {
+		t.Fatalf("template %+v", tpl)
+	}
+
+	n := Note{Id: uuid.New(), Title: "Plain", Tags: []string{"a", "b"}, DateCreated: time.Now().UTC()}
+	out, err := tpl.Render(n, []byte("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\n# Plain\n{{ .Values.name }} a,b\ncontent\n"; string(out) != want {
+		t.Errorf("rendered %q, want %q", out, want)
+	}
+}
+
+func TestTextTemplate(t *testing.T) {
+	testDataDir(t)
+
+	testWriteTemplate(t, "header", "# {{ nm.title }}\n")
+	testWriteTemplate(t, "meeting.tmpl", "---\ntags: [meeting]\n---\n{{ include \"header\" }}{{ .Note.Title }} {{ .Config.ServeToken }}|\n")
+	notemanager.ServeToken = "secret"
+
+	tpl, err := loadTemplate("meeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Plain || len(tpl.Tags) != 1 {
+		t.Fatalf("template %+v", tpl)
+	}
+
+	n := Note{Id: uuid.New(), Title: "Weekly", DateCreated: time.Now().UTC()}
+	out, err := tpl.Render(n, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# Weekly\nWeekly |\n"; string(out) != want {
+		t.Errorf("rendered %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Markdown checkbox, e.g. "- [ ] item" or "* [x] item"
+var reTodo = regexp.MustCompile(`^(\s*[-*+]\s+\[)([ xX])(\].*)$`)
+
+// checkbox item of a note
+type Todo struct {
+	// line number of the item, starting at 1
+	Line int
+	Done bool
+	Text string
+}
+
+// returns checkbox items of content
+func parseTodos(content []byte) (todos []Todo) {
+	for i, line := range strings.Split(string(content), "\n") {
+		m := reTodo.FindStringSubmatch(strings.TrimSuffix(line, "\r"))
+		if m == nil {
+			continue
+		}
+
+		todos = append(todos, Todo{
+			Line: i + 1,
+			Done: m[2] != " ",
+			Text: strings.TrimSpace(strings.TrimPrefix(m[3], "]")),
+		})
+	}
+	return
+}
+
+// returns true if content has unchecked checkbox items
+func hasOpenTodos(content []byte) bool {
+	for _, t := range parseTodos(content) {
+		if t.Done == false {
+			return true
+		}
+	}
+	return false
+}
+
+// CMD: note [FILTER] todos [OPTIONS]
+func todosHandler(notes []Note, args []string) (err error) {
+	var optHelp bool
+	var optOpen bool
+	fs := flag.NewFlagSet("note todos", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteTodos() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	fs.BoolVar(&optOpen, "o", false, "Display open items only")
+	fs.BoolVar(&optOpen, "open", false, "Display open items only")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fs.NArg() > 0 {
+		helpNoteTodos()
+	}
+
+	var count int
+	for _, n := range notes {
+		var lines []string
+		for _, t := range parseTodos(n.latestContent) {
+			if optOpen && t.Done {
+				continue
+			}
+
+			box := "[ ]"
+			if t.Done {
+				box = "[x]"
+			}
+			lines = append(lines, fmt.Sprintf("  %4d  %s %s", t.Line, box, t.Text))
+		}
+
+		if len(lines) == 0 {
+			continue
+		}
+
+		fmt.Printf("%s: %s\n%s\n", n.ShortId(), n.Title, strings.Join(lines, "\n"))
+		count += len(lines)
+	}
+
+	if count == 0 {
+		fmt.Println("No todo items found")
+	}
+
+	return
+}
+
+// CMD: note ID todo done|open LINE
+func todoHandler(notes []Note, args []string) (err error) {
+	var optHelp bool
+	fs := flag.NewFlagSet("note todo", flag.ContinueOnError)
+	fs.Usage = func() { helpNoteTodos() }
+	fs.BoolVar(&optHelp, "h", false, "Display usage")
+	fs.BoolVar(&optHelp, "help", false, "Display usage")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	args = fs.Args()
+	if optHelp || len(args) != 2 {
+		helpNoteTodos()
+	}
+
+	if len(notes) != 1 {
+		Exit("Select a single note")
+	}
+
+	var done bool
+	switch args[0] {
+	case "done":
+		done = true
+
+	case "open":
+		done = false
+
+	default:
+		helpNoteTodos()
+	}
+
+	line, err := strconv.Atoi(args[1])
+	if err != nil {
+		Exit("Invalid line number: " + args[1])
+	}
+
+	err = noteTodoHandler(notes[0], line, done)
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// Check or uncheck the checkbox item at line of note n and create
+// a new version.
+func noteTodoHandler(n Note, line int, done bool) (err error) {
+	lines := strings.Split(string(n.latestContent), "\n")
+	if line < 1 || line > len(lines) {
+		return fmt.Errorf("%s: No todo item at line %d", n.ShortId(), line)
+	}
+
+	m := reTodo.FindStringSubmatch(lines[line-1])
+	if m == nil {
+		return fmt.Errorf("%s: No todo item at line %d", n.ShortId(), line)
+	}
+
+	box := " "
+	if done {
+		box = "x"
+	}
+
+	if (m[2] != " ") == done {
+		if done {
+			return errors.New("Todo item is already done")
+		}
+		return errors.New("Todo item is already open")
+	}
+	lines[line-1] = m[1] + box + m[3]
+
+	err = os.WriteFile(n.tmpFile(), []byte(strings.Join(lines, "\n")), notemanager.FilePermission)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return commitNoteEdit(n, n.LatestVersion())
+}
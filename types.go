@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
@@ -16,16 +17,24 @@ import (
 )
 
 type Note struct {
-	Id            uuid.UUID    `yaml:"id"`
-	Title         string       `yaml:"title"`
-	Alias         string       `yaml:"alias,omitempty"`
-	Attachments   []Attachment `yaml:"attachments,omitempty"`
-	Versions      []string     `yaml:"versions"`
-	Tags          []string     `yaml:"tags,omitempty"`
-	VirtualTags   []string     `yaml:"-"`
-	DateCreated   time.Time    `yaml:"created"`
-	DateModified  []time.Time  `yaml:"modified,omitempty"`
-	DateDeleted   time.Time    `yaml:"deleted,omitempty"`
+	Id            uuid.UUID         `yaml:"id"`
+	Title         string            `yaml:"title"`
+	Alias         string            `yaml:"alias,omitempty"`
+	Attachments   []Attachment      `yaml:"attachments,omitempty"`
+	Versions      []string          `yaml:"versions"`
+	Tags          []string          `yaml:"tags,omitempty"`
+	Journal       string            `yaml:"journal,omitempty"`
+	Attributes    map[string]string `yaml:"attributes,omitempty"`
+	Pinned        bool              `yaml:"pinned,omitempty"`
+	Priority      int               `yaml:"priority,omitempty"`
+	Conflicts     []string          `yaml:"conflicts,omitempty"`
+	VirtualTags   []string          `yaml:"-"`
+	DateCreated   time.Time         `yaml:"created"`
+	DateModified  []time.Time       `yaml:"modified,omitempty"`
+	DateDeleted   time.Time         `yaml:"deleted,omitempty"`
+	DateArchived  time.Time         `yaml:"archived,omitempty"`
+	DateDue       time.Time         `yaml:"due,omitempty"`
+	DateReview    time.Time         `yaml:"review,omitempty"`
 	latestContent []byte
 }
 
@@ -41,6 +50,56 @@ type NoteFilter struct {
 	HasFile        bool
 	Notes          []string
 	Aliases        []string
+	Attributes     []AttributeFilter
+}
+
+// Filter term of user defined attribute.
+// key:value, key.not:value, key: (not set), key.not: (set)
+type AttributeFilter struct {
+	Key    string
+	Value  string
+	Negate bool
+}
+
+// syntax of user defined attribute names and terms, e.g. project:apollo.
+// The value follows the colon without space, so "re: meeting" is no term.
+var reAttributeKey = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+var reAttribute = regexp.MustCompile(`^([a-z][a-z0-9_]*)(\.not)?:(\S.*)?$`)
+
+// attribute keys which are used by filter terms, dates of notes or
+// columns of note list
+var reservedAttributes = []string{
+	"alias",
+	"created",
+	"due",
+	"id",
+	"modified",
+	"pinned",
+	"review",
+	"tags",
+	"title",
+}
+
+// attributes which are dates of notes, set by modify
+var dateAttributes = []string{
+	"due",
+	"review",
+}
+
+// syntax of tags. Levels of hierarchical tags are separated by dots,
+// e.g. work.clientA
+var reTag = regexp.MustCompile(`^[\pL0-9]+(\.[\pL0-9]+)*$`)
+
+// Slashes are accepted as separator of hierarchical tags as well,
+// but tags are stored with dots.
+func normalizeTag(t string) string {
+	return strings.ReplaceAll(t, "/", ".")
+}
+
+// returns true if tag equals x or is a sub tag of x.
+// E.g. work.clientA matches work.
+func tagMatches(tag string, x string) bool {
+	return tag == x || strings.HasPrefix(tag, x+".")
 }
 
 type NoteAliases map[string]uuid.UUID
@@ -73,6 +132,28 @@ type Config struct {
 	FilePermission         os.FileMode
 	FilePermissionReadonly os.FileMode
 	DirPermission          os.FileMode
+	// abort if a note cannot be loaded instead of skipping it
+	Strict bool
+	// render Markdown of read and print in the terminal
+	RenderMarkdown bool
+	// name of the template in TemplateDir for the header of read and print
+	OutputTemplate string
+	// extension of temporary files opened in the editor, e.g. .md
+	TempFileExtension string
+	// name of the notebook in use
+	Notebook string
+	// data directories of notebooks by name
+	Notebooks map[string]string
+	// token clients of the API of note serve must send
+	ServeToken string
+}
+
+// Manifest of a backup archive. Maps the slash separated path of every
+// archived file, relative to the data directory, to its sha1 checksum.
+type BackupManifest struct {
+	Version int               `yaml:"version"`
+	Created time.Time         `yaml:"created"`
+	Files   map[string]string `yaml:"files"`
 }
 
 type Attachment struct {
@@ -81,6 +162,40 @@ type Attachment struct {
 	DateCreated time.Time `yaml:"dateCreated"`
 }
 
+// Attach a copy of file as name and save to data file. Files with the
+// same name or checksum as an attachment of the note are rejected.
+func (n *Note) Attach(file string, name string) (err error) {
+	sha1, err := fileSha1(file)
+	if err != nil {
+		return
+	}
+	for _, a := range n.Attachments {
+		if sha1 == a.Sha1 {
+			return fmt.Errorf("File with same checksum already attached: %s.", a.Filename)
+		}
+	}
+
+	dstDir := filepath.Clean(n.Path() + "/attachments")
+	err = os.MkdirAll(dstDir, os.FileMode(notemanager.DirPermission))
+	if err != nil {
+		return
+	}
+	err = copyRegularFile(file, filepath.Clean(dstDir+"/"+name))
+	if err != nil {
+		if err.Error() == "File already exists. Aborting." {
+			err = fmt.Errorf("File already attached. Use another name: %s.", name)
+		}
+		return
+	}
+
+	n.Attachments = append(n.Attachments, Attachment{
+		Filename:    name,
+		Sha1:        sha1,
+		DateCreated: time.Now().UTC(),
+	})
+	return n.WriteData()
+}
+
 // checks if note exists
 func (n Note) Exists() bool {
 	if _, err := os.Stat(filepath.Clean(notemanager.NoteDir + "/" + n.Id.String())); errors.Is(err, os.ErrNotExist) {
@@ -92,7 +207,7 @@ func (n Note) Exists() bool {
 
 // write yaml encoded note struct to data file
 func (n Note) WriteData() (err error) {
-	err = os.WriteFile(filepath.Clean(notemanager.NoteDir+"/"+n.Id.String()+"/data"), n.Yaml(), 0600)
+	err = writeFileAtomic(filepath.Clean(notemanager.NoteDir+"/"+n.Id.String()+"/data"), n.Yaml(), notemanager.FilePermission)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -168,10 +283,19 @@ func loadNote(id string) (n Note, err error) {
 
 	err = yaml.Unmarshal(yml, &n)
 	if err != nil {
-		log.Fatal(err)
+		err = fmt.Errorf("Data file cannot be parsed: %s", err)
+		return
+	}
+
+	if len(n.Versions) == 0 {
+		err = errors.New("Note has no versions")
+		return
 	}
 
 	n.latestContent, err = n.Content()
+	if err != nil {
+		return
+	}
 
 	// build virtual tags
 	if n.DateCreated.Year() == time.Now().Year() {
@@ -213,39 +337,80 @@ func loadNote(id string) (n Note, err error) {
 		n.VirtualTags = append(n.VirtualTags, `FILE`)
 	}
 
+	if n.Pinned {
+		n.VirtualTags = append(n.VirtualTags, `PINNED`)
+	}
+
+	if n.DateArchived.IsZero() == false {
+		n.VirtualTags = append(n.VirtualTags, `ARCHIVED`)
+	}
+
+	if n.DateDue.IsZero() == false {
+		switch deadline := dueDeadline(n.DateDue); {
+		case time.Now().After(deadline):
+			n.VirtualTags = append(n.VirtualTags, `OVERDUE`)
+
+		case deadline.Before(time.Now().AddDate(0, 0, agendaDays)):
+			n.VirtualTags = append(n.VirtualTags, `DUE`)
+		}
+	}
+
+	if len(n.Conflicts) > 0 {
+		n.VirtualTags = append(n.VirtualTags, `CONFLICT`)
+	}
+
+	if hasOpenTodos(n.latestContent) {
+		n.VirtualTags = append(n.VirtualTags, `OPENTODO`)
+	}
+
+	if n.DateReview.IsZero() == false && time.Now().Before(n.DateReview) == false {
+		n.VirtualTags = append(n.VirtualTags, `REVIEW`)
+	}
+
 	return
 }
 
-// creates text output of note.
-func (n Note) Output(version string) (b []byte) {
-	tpl := `+
-+ Title:       %s
-+ Date:        %s
-+
-+ Tags:        %s
-+ Attachments: %d
-+ Version:     %s
-+
+// options of the text output of notes
+type OutputOptions struct {
+	Version string
+	// render content as Markdown for the terminal
+	Render  bool
+	Header  bool
+	Content bool
+}
 
+// creates text output of note. The header is rendered with the output
+// template, the content is appended to it.
+func (n Note) Output(o OutputOptions) (b []byte, err error) {
+	if o.Version == "" {
+		o.Version = n.LatestVersion()
+	}
 
-%s
-`
+	if o.Header {
+		b, err = n.Header(o.Version)
+		if err != nil {
+			return
+		}
+		if o.Content == false {
+			b = bytes.TrimRight(b, "\n")
+		}
+	}
 
-	content, err := n.Content(version)
-	if err != nil {
-		log.Fatal(err)
+	if o.Content {
+		var content []byte
+		content, err = n.Content(o.Version)
+		if err != nil {
+			return
+		}
+		if o.Render {
+			content = renderMarkdown(content, terminalWidth())
+		}
+		b = append(b, content...)
 	}
-	s := fmt.Sprintf(tpl,
-		n.Title,
-		n.DateCreated,
-		strings.Join(n.Tags, ", "),
-		len(n.Attachments),
-		//n.Versions[len(n.Versions)-1],
-		version,
-		content,
-	)
 
-	b = []byte(s)
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
 
 	return
 }
@@ -268,6 +433,41 @@ func (n Note) Undelete() (err error) {
 	return
 }
 
+// Set the DateArchived value and save to data file
+func (n Note) Archive() (err error) {
+	if n.DateArchived.IsZero() == false {
+		return
+	}
+
+	n.DateArchived = time.Now().UTC()
+	err = n.WriteData()
+	return
+}
+
+// Delete the DateArchived value and save to data file
+func (n Note) Unarchive() (err error) {
+	n.DateArchived = time.Time{}
+	err = n.WriteData()
+	return
+}
+
+// Pin note with priority and save to data file. Pinned notes are
+// listed first, ordered by priority.
+func (n Note) Pin(priority int) (err error) {
+	n.Pinned = true
+	n.Priority = priority
+	err = n.WriteData()
+	return
+}
+
+// Unpin note and save to data file
+func (n Note) Unpin() (err error) {
+	n.Pinned = false
+	n.Priority = 0
+	err = n.WriteData()
+	return
+}
+
 // UUIDs are long and clumsy
 func (n Note) ShortId() (s string) {
 	return n.Id.String()[0:8]
@@ -280,14 +480,20 @@ func (n Note) MatchesFilter(filter NoteFilter) (ret bool, err error) {
 			ret = false
 			return
 		}
+
+		// archived notes are hidden, unless asked for explicitly
+		if n.DateArchived.IsZero() == false && slices.Contains(filter.TagsInclude, "ARCHIVED") == false {
+			ret = false
+			return
+		}
 	}
 
 	// Must have tags
 	for _, x := range filter.TagsInclude {
 		exists := false
-		// explicit note tags
+		// explicit note tags, including sub tags
 		for _, t := range n.Tags {
-			if t == x {
+			if tagMatches(t, x) {
 				exists = true
 				continue
 			}
@@ -308,9 +514,9 @@ func (n Note) MatchesFilter(filter NoteFilter) (ret bool, err error) {
 	// Must not have tags
 	for _, x := range filter.TagsExclude {
 		exists := false
-		// explicit note tags
+		// explicit note tags, including sub tags
 		for _, t := range n.Tags {
-			if t == x {
+			if tagMatches(t, x) {
 				exists = true
 				continue
 			}
@@ -336,6 +542,16 @@ func (n Note) MatchesFilter(filter NoteFilter) (ret bool, err error) {
 		}
 	}
 
+	// user defined attributes
+	// An empty value matches notes without the attribute.
+	for _, a := range filter.Attributes {
+		matches := n.Attributes[a.Key] == a.Value
+		if matches == a.Negate {
+			ret = false
+			return
+		}
+	}
+
 	// check timestamps
 
 	// created.before
@@ -392,8 +608,9 @@ func (n Note) MatchesFilter(filter NoteFilter) (ret bool, err error) {
 // the single tag is skipped, but the other tags are added
 func (n *Note) AddTags(t []string) error {
 	for _, v := range t {
-		if regexp.MustCompile(`^[\pL0-9]+$`).MatchString(v) == false {
-			return fmt.Errorf("Error: Tags must be alphanumeric")
+		v = normalizeTag(v)
+		if reTag.MatchString(v) == false {
+			return fmt.Errorf("Error: Tags must be alphanumeric, levels separated by dots")
 		}
 
 		if slices.Contains(n.Tags, v) {
@@ -407,6 +624,9 @@ func (n *Note) AddTags(t []string) error {
 // removes tags from note. if one of the notes does not exist
 // the other notes are still removed
 func (n *Note) RemoveTags(t []string) {
+	for k, v := range t {
+		t[k] = normalizeTag(v)
+	}
 RESTART:
 	for k, v := range n.Tags {
 		if slices.Contains(t, v) {
@@ -426,6 +646,50 @@ func (n *Note) RemoveTag(t string) {
 	n.RemoveTags([]string{t})
 }
 
+// set due or review date of note. The value is parsed like timestamps
+// of filter terms. An empty value removes the date.
+func (n *Note) SetDate(key string, value string) (err error) {
+	var ts time.Time
+	if value != "" {
+		ts, err = parseTimestamp(value)
+		if err != nil {
+			return fmt.Errorf("Error: Invalid %s date: %s", key, value)
+		}
+		ts = ts.UTC()
+	}
+
+	switch key {
+	case "due":
+		n.DateDue = ts
+
+	case "review":
+		n.DateReview = ts
+
+	default:
+		return fmt.Errorf("Error: Invalid date attribute: %s", key)
+	}
+
+	return
+}
+
+// set user defined attribute. An empty value removes the attribute.
+func (n *Note) SetAttribute(key string, value string) error {
+	if reAttributeKey.MatchString(key) == false || slices.Contains(reservedAttributes, key) {
+		return fmt.Errorf("Error: Invalid attribute name: %s", key)
+	}
+
+	if value == "" {
+		delete(n.Attributes, key)
+		return nil
+	}
+
+	if n.Attributes == nil {
+		n.Attributes = make(map[string]string)
+	}
+	n.Attributes[key] = value
+	return nil
+}
+
 func (n *Note) RemoveAlias() {
 	n.Alias = ""
 }
@@ -444,9 +708,28 @@ func (n Note) LatestVersion() string {
 	return n.Versions[len(n.Versions)-1]
 }
 
+// returns name of a new version created at time t. Version names have
+// a resolution of seconds, so t is increased until the name is unique.
+func (n Note) newVersion(t time.Time) (version string) {
+	for {
+		version = t.UTC().Format(notemanager.VersionTimeFormat)
+		if slices.Contains(n.Versions, version) == false {
+			return
+		}
+		t = t.Add(time.Second)
+	}
+}
+
+// returns path of the temporary file used to edit the note.
+// The process id is part of the name, so concurrent edits of the
+// same note do not share a file.
+func (n Note) tmpFile() string {
+	return filepath.Clean(fmt.Sprintf("%s/%s.%d%s", notemanager.TempDir, n.Id.String(), os.Getpid(), notemanager.TempFileExtension))
+}
+
 // moves temporary note from tempDir to specific note directory inside noteDir
 func (n Note) moveTmpFile() (err error) {
-	oldFile := filepath.Clean(notemanager.TempDir + `/` + n.Id.String())
+	oldFile := n.tmpFile()
 	newFile := filepath.Clean(n.Path() + `/` + n.LatestVersion())
 
 	os.MkdirAll(n.Path(), notemanager.DirPermission)
@@ -472,7 +755,7 @@ func (a *NoteAliases) Load() (err error) {
 }
 
 func (a *NoteAliases) Write() (err error) {
-	err = os.WriteFile(filepath.Clean(notemanager.DataDir+"/aliases"), a.Yaml(), 0600)
+	err = a.WriteFile(filepath.Clean(notemanager.DataDir + "/aliases"))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -480,6 +763,26 @@ func (a *NoteAliases) Write() (err error) {
 	return
 }
 
+// Write aliases to path, e.g. the aliases file of another notebook
+func (a *NoteAliases) WriteFile(path string) (err error) {
+	return writeFileAtomic(path, a.Yaml(), notemanager.FilePermission)
+}
+
+// Read aliases file of path, e.g. of another notebook
+func readAliases(path string) (a NoteAliases, err error) {
+	a = make(NoteAliases)
+	yml, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	err = yaml.Unmarshal(yml, &a)
+	if a == nil {
+		a = make(NoteAliases)
+	}
+	return
+}
+
 // encode NoteAliases struct to yaml
 func (a *NoteAliases) Yaml() (encodedYaml []byte) {
 	encodedYaml, err := yaml.Marshal(a)
@@ -510,15 +813,40 @@ func (a *NoteAliases) Set(alias string, noteId uuid.UUID) {
 	blocklist := []string{
 		"",
 		"add",
+		"agenda",
 		"alias",
+		"append",
+		"archive",
+		"backup",
+		"config",
+		"copy",
 		"delete",
+		"drafts",
 		"edit",
+		"fsck",
+		"journal",
 		"list",
+		"migrate-datadir",
 		"modify",
+		"move",
+		"notebook",
+		"pin",
+		"pinned",
+		"prepend",
+		"restore-archive",
 		"search",
+		"serve",
+		"sync",
 		"tags",
+		"template",
+		"templates",
+		"todo",
+		"todos",
+		"unarchive",
+		"unpin",
 		"version",
 		"versions",
+		"web",
 	}
 	if slices.Contains(blocklist, alias) {
 		Exit("Cannot set alias: " + alias)
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"crypto/subtle"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// templates and style sheet of note web, so it works offline
+//
+//go:embed web
+var webAssets embed.FS
+
+// read-only web interface of the notes
+type webServer struct {
+	mu        sync.Mutex
+	token     string
+	templates *template.Template
+	static    http.Handler
+}
+
+// tag of the sidebar, sub tags are indented by depth
+type webTag struct {
+	Path  string
+	Name  string
+	Depth int
+	Count int
+}
+
+// data of the page templates
+type webPage struct {
+	Title   string
+	Filter  string
+	Tag     string
+	Tags    []webTag
+	Notes   []Note
+	Note    Note
+	Version string
+	From    string
+	Content template.HTML
+	Diff    []DiffLine
+	Error   string
+}
+
+// CMD: note web [--listen ADDR]
+func webHandler(args []string) (err error) {
+	var optHelp bool
+	var optListen string
+	fls := flag.NewFlagSet("note web", flag.ContinueOnError)
+	fls.Usage = func() { helpNoteWeb() }
+	fls.BoolVar(&optHelp, "h", false, "Display usage")
+	fls.BoolVar(&optHelp, "help", false, "Display usage")
+	fls.StringVar(&optListen, "l", "127.0.0.1:8081", "Listen address")
+	fls.StringVar(&optListen, "listen", "127.0.0.1:8081", "Listen address")
+	if err = fls.Parse(args); err != nil {
+		return
+	}
+
+	if optHelp || fls.NArg() > 0 {
+		helpNoteWeb()
+	}
+
+	host, _, err := net.SplitHostPort(optListen)
+	if err != nil {
+		Exit(err.Error())
+	}
+	if ip := net.ParseIP(host); notemanager.ServeToken == "" && host != "localhost" && (ip == nil || ip.IsLoopback() == false) {
+		fmt.Fprintln(os.Stderr, "Warning: No serveToken set, the notes can be read by everyone who can reach "+optListen)
+	}
+
+	handler, err := newWebServer(notemanager.ServeToken)
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	server := &http.Server{
+		Addr:              optListen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("Serving notes of %s on http://%s\n", notemanager.DataDir, optListen)
+	err = server.ListenAndServe()
+	if err != nil {
+		Exit(err.Error())
+	}
+
+	return
+}
+
+// returns handler of the web interface. If token is set, it is
+// required as password of HTTP basic authentication.
+func newWebServer(token string) (http.Handler, error) {
+	funcs := template.FuncMap{
+		"date": func(t time.Time) string {
+			return t.Local().Format(notemanager.OutputTimeFormatShort)
+		},
+		"join": strings.Join,
+		// date of the last modification, empty if never modified
+		"modified": func(n Note) string {
+			if len(n.DateModified) == 0 {
+				return ""
+			}
+			return n.DateModified[len(n.DateModified)-1].Local().Format(notemanager.OutputTimeFormatShort)
+		},
+		"reverse": func(s []string) []string {
+			r := make([]string, 0, len(s))
+			for i := len(s) - 1; i >= 0; i-- {
+				r = append(r, s[i])
+			}
+			return r
+		},
+	}
+
+	templates, err := template.New("web").Funcs(funcs).ParseFS(webAssets, "web/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	static, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		return nil, err
+	}
+
+	return &webServer{
+		token:     token,
+		templates: templates,
+		static:    http.StripPrefix("/static/", http.FileServer(http.FS(static))),
+	}, nil
+}
+
+func (s *webServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		_, password, _ := r.BasicAuth()
+		if subtle.ConstantTimeCompare([]byte(password), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="note"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/static/") && strings.HasSuffix(r.URL.Path, ".css") {
+		s.static.ServeHTTP(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// aliases may have been changed by other processes
+	var err error
+	aliases, err = readAliases(notemanager.AliasesPath)
+	if err != nil {
+		s.renderError(w, err)
+		return
+	}
+
+	page := webPage{Filter: r.URL.Query().Get("filter"), Tag: r.URL.Query().Get("tag")}
+	page.Tags, err = webTags()
+	if err != nil {
+		s.renderError(w, err)
+		return
+	}
+
+	err = s.route(w, r, page)
+	if err != nil {
+		s.renderError(w, err)
+	}
+}
+
+// dispatch request by path
+func (s *webServer) route(w http.ResponseWriter, r *http.Request, page webPage) (err error) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.URL.Path == "/":
+		return s.list(w, r, page)
+
+	case path[0] != "notes" || len(path) < 2:
+		return apiError{http.StatusNotFound, errors.New("Not found: " + r.URL.Path)}
+	}
+
+	page.Note, err = apiFindNote(path[1])
+	if err != nil {
+		return
+	}
+	page.Title = page.Note.Title
+
+	switch {
+	case len(path) == 2:
+		return s.note(w, r, page)
+
+	case len(path) == 3 && path[2] == "diff":
+		return s.diff(w, r, page)
+
+	case len(path) == 4 && path[2] == "attachments":
+		return apiDownloadAttachment(w, r, page.Note, path[3])
+	}
+
+	return apiError{http.StatusNotFound, errors.New("Not found: " + r.URL.Path)}
+}
+
+// GET /?filter=TERMS&tag=TAG
+func (s *webServer) list(w http.ResponseWriter, r *http.Request, page webPage) (err error) {
+	filter, err := apiFilter(r)
+	if err != nil {
+		return
+	}
+	if page.Tag != "" {
+		filter.TagsInclude = append(filter.TagsInclude, normalizeTag(page.Tag))
+	}
+
+	page.Notes, err = notes(filter)
+	if err != nil {
+		return
+	}
+
+	// pinned notes first, latest notes first
+	sort.SliceStable(page.Notes, func(i, j int) bool {
+		a, b := page.Notes[i], page.Notes[j]
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.DateCreated.After(b.DateCreated)
+	})
+
+	return s.render(w, "list", page)
+}
+
+// GET /notes/ID?version=VERSION
+func (s *webServer) note(w http.ResponseWriter, r *http.Request, page webPage) (err error) {
+	page.Version, err = webVersion(r, page.Note)
+	if err != nil {
+		return
+	}
+
+	content, err := page.Note.Content(page.Version)
+	if err != nil {
+		return
+	}
+	page.Content = template.HTML(renderMarkdownHTML(content))
+
+	return s.render(w, "note", page)
+}
+
+// GET /notes/ID/diff?version=VERSION[&from=VERSION]
+// Changes of VERSION, by default against the previous version.
+func (s *webServer) diff(w http.ResponseWriter, r *http.Request, page webPage) (err error) {
+	page.Version, err = webVersion(r, page.Note)
+	if err != nil {
+		return
+	}
+
+	page.From = r.URL.Query().Get("from")
+	if page.From == "" {
+		i := slices.Index(page.Note.Versions, page.Version)
+		if i == 0 {
+			return apiError{http.StatusBadRequest, errors.New("First version has no previous version")}
+		}
+		page.From = page.Note.Versions[i-1]
+	}
+	if slices.Contains(page.Note.Versions, page.From) == false {
+		return apiError{http.StatusNotFound, errors.New("Version not found: " + page.From)}
+	}
+
+	from, err := page.Note.Content(page.From)
+	if err != nil {
+		return
+	}
+	to, err := page.Note.Content(page.Version)
+	if err != nil {
+		return
+	}
+	page.Diff = diffLines(splitLines(from), splitLines(to))
+
+	return s.render(w, "diff", page)
+}
+
+// returns version of query parameter version, by default the latest
+func webVersion(r *http.Request, n Note) (version string, err error) {
+	version = r.URL.Query().Get("version")
+	if version == "" {
+		return n.LatestVersion(), nil
+	}
+	if slices.Contains(n.Versions, version) == false {
+		err = apiError{http.StatusNotFound, errors.New("Version not found: " + version)}
+	}
+	return
+}
+
+// returns tags of all notes as flat tree for the sidebar
+func webTags() (tags []webTag, err error) {
+	all, err := notes(NoteFilter{})
+	if err != nil {
+		return
+	}
+
+	var walk func(node *TagNode, depth int)
+	walk = func(node *TagNode, depth int) {
+		for _, c := range node.sortedChildren("name") {
+			tags = append(tags, webTag{Path: c.Path, Name: c.Name, Depth: depth, Count: c.Count()})
+			walk(c, depth+1)
+		}
+	}
+	walk(tagTree(all), 0)
+	return
+}
+
+func (s *webServer) render(w http.ResponseWriter, name string, page webPage) error {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return s.templates.ExecuteTemplate(w, name, page)
+}
+
+// render error page, with the status of apiError
+func (s *webServer) renderError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var e apiError
+	if errors.As(err, &e) {
+		status = e.status
+	}
+
+	page := webPage{Title: "Error", Error: err.Error()}
+	page.Tags, _ = webTags()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	s.templates.ExecuteTemplate(w, "error", page)
+}